@@ -4,6 +4,7 @@ import (
 	"bytes"
 	"crypto/hmac"
 	"crypto/sha1"
+	"crypto/sha256"
 	"crypto/subtle"
 	"encoding/json"
 	"errors"
@@ -14,9 +15,12 @@ import (
 	"io/ioutil"
 	"log"
 	"net/http"
+	"net/url"
 	"os"
 	"os/exec"
 	"path/filepath"
+	"regexp"
+	"strings"
 	"time"
 )
 
@@ -26,6 +30,8 @@ var (
 	addr    = flag.String("addr", ":8124", "binding address to listen on")
 	secret  = flag.String("secret", "",
 	"Optional secret for authenticating hooks")
+	requireSHA256 = flag.Bool("require-sha256", false,
+	"Reject GitHub hooks without a SHA-256 signature")
 )
 
 type commandRequest struct {
@@ -186,19 +192,12 @@ func getPath(req *http.Request) string {
 	return filepath.Clean(filepath.FromSlash(req.URL.Path))[1:]
 }
 
-func createRepo(w http.ResponseWriter, path string, repo_path string,
-	private bool, bg bool, payload []byte) bool {
-	repo := fmt.Sprintf("git://github.com/%v.git",
-		repo_path)
-	if private {
-		repo = fmt.Sprintf("git@github.com:%v.git",
-			repo_path)
-	}
-
+func createRepo(w http.ResponseWriter, path string, repo string,
+	bg bool, payload []byte) bool {
 	abspath := filepath.Join(*thePath, path)
 
 	cmds := []*exec.Cmd{
-		exec.Command(*git, "clone", "--mirror", "--bare", repo,
+		exec.Command(*git, "clone", "--mirror", "--bare", "--", repo,
 			filepath.Join(*thePath, path)),
 		exec.Command(filepath.Join(abspath, "hooks/post-clone")),
 		exec.Command(filepath.Join(*thePath, "bin/post-clone")),
@@ -224,13 +223,112 @@ func doUpdate(w http.ResponseWriter, path string,
 	}
 }
 
-func doCreate(w http.ResponseWriter, path string, repo_path string,
-	private bool, bg bool, payload []byte) {
+func doCreate(w http.ResponseWriter, path string, repo string,
+	bg bool, payload []byte) {
 	if bg {
-		go createRepo(w, path, repo_path, private, bg, payload)
+		go createRepo(w, path, repo, bg, payload)
 		w.WriteHeader(201)
 	} else {
-		createRepo(w, path, repo_path, private, bg, payload)
+		createRepo(w, path, repo, bg, payload)
+	}
+}
+
+var scpURL = regexp.MustCompile(`^(?:[^@/]+@)?([^:/]+):`)
+var validHost = regexp.MustCompile(`^[a-z0-9]([a-z0-9.-]*[a-z0-9])?$`)
+
+// repoHost returns the host of a clone URL, eg. github.com for
+// https://github.com/foo/bar.git or git@github.com:foo/bar.git
+func repoHost(repo string) string {
+	host := ""
+	if u, err := url.Parse(repo); err == nil && u.Host != "" {
+		host = u.Hostname()
+	} else if m := scpURL.FindStringSubmatch(repo); m != nil {
+		host = m[1]
+	}
+	host = strings.ToLower(host)
+	if !validHost.MatchString(host) || strings.Contains(host, "..") {
+		return ""
+	}
+	return host
+}
+
+// validCloneURL accepts https, ssh and scp-like URLs, eg.
+// git@github.com:foo/bar.git. Anything else, eg. local paths,
+// transport helpers or options, is rejected as it comes from
+// the payload.
+func validCloneURL(repo string) bool {
+	if strings.HasPrefix(repo, "-") || strings.Contains(repo, "::") ||
+		repoHost(repo) == "" {
+		return false
+	}
+	if strings.Contains(repo, "://") {
+		u, err := url.Parse(repo)
+		return err == nil && (u.Scheme == "https" || u.Scheme == "ssh")
+	}
+	return scpURL.MatchString(repo)
+}
+
+// originHost returns the host the mirror at path was cloned from.
+func originHost(path string) string {
+	out, err := exec.Command(*git, "--git-dir="+filepath.Join(*thePath, path),
+		"config", "remote.origin.url").Output()
+	if err != nil {
+		return ""
+	}
+	return repoHost(strings.TrimSpace(string(out)))
+}
+
+// mirrorPath returns where the mirror of repo is kept, eg.
+// github.com/foo/bar, so repositories of the same name on
+// different hosts don't share a mirror. Mirrors created
+// before the host was part of the path are kept in place
+// if they were cloned from the same host.
+func mirrorPath(path string, repo string) (string, error) {
+	host := repoHost(repo)
+	legacy := exists(filepath.Join(*thePath, path))
+	if host == "" {
+		if repo == "" && legacy {
+			return path, nil
+		} else if repo == "" {
+			return "", errors.New("No clone URL in payload")
+		}
+		return "", errors.New("Invalid clone URL")
+	}
+
+	mirror := filepath.Join(host, path)
+	if !exists(filepath.Join(*thePath, mirror)) && legacy &&
+		originHost(path) == host {
+		return path, nil
+	}
+	return mirror, nil
+}
+
+// doMirror updates the mirror of a repository, cloning it
+// from repo first if there's none yet.
+func doMirror(w http.ResponseWriter, path string, repo string,
+	bg bool, payload []byte) {
+	path = filepath.Clean(filepath.FromSlash(path))
+	if path == "." || filepath.IsAbs(path) || path == ".." ||
+		strings.HasPrefix(path, ".."+string(filepath.Separator)) {
+		http.Error(w, "Invalid repository path", http.StatusBadRequest)
+		return
+	}
+
+	if repo != "" && !validCloneURL(repo) {
+		http.Error(w, "Invalid clone URL", http.StatusBadRequest)
+		return
+	}
+
+	path, err := mirrorPath(path, repo)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	if exists(filepath.Join(*thePath, path)) {
+		doUpdate(w, path, bg, payload)
+	} else {
+		doCreate(w, path, repo, bg, payload)
 	}
 }
 
@@ -254,24 +352,64 @@ func readPayload(r io.Reader) ([]byte, error) {
 }
 
 func checkHMAC(h hash.Hash, sig string) bool {
-	got := fmt.Sprintf("sha1=%x", h.Sum(nil))
-	return len(got) == len(sig) && subtle.ConstantTimeCompare(
-		[]byte(got), []byte(sig)) == 1
+	return checkSignature(h, "sha1=", sig)
+}
+
+func checkHMAC256(h hash.Hash, sig string) bool {
+	return checkSignature(h, "sha256=", sig)
+}
+
+// checkSignature compares sig to the hex digest of h
+// following prefix, in constant time.
+func checkSignature(h hash.Hash, prefix string, sig string) bool {
+	got := fmt.Sprintf("%v%x", prefix, h.Sum(nil))
+	return checkToken(got, sig)
+}
+
+func checkToken(want string, got string) bool {
+	return len(want) == len(got) && subtle.ConstantTimeCompare(
+		[]byte(want), []byte(got)) == 1
+}
+
+// githubCloneURL checks the clone URL of a GitHub payload. It has
+// to be on the host of the repository. Without a secret anyone can
+// send payloads, so only github.com is trusted then.
+func githubCloneURL(repo string, html string) (string, error) {
+	host := repoHost(repo)
+	switch {
+	case repo == "" || host == "github.com":
+		return repo, nil
+	case *secret == "":
+		return "", errors.New("Only github.com is mirrored without a secret")
+	case host == "" || host != repoHost(html):
+		return "", errors.New("Clone URL is not on the host of the repository")
+	}
+	return repo, nil
 }
 
 func handleGitHubCallback(w http.ResponseWriter, req *http.Request, bg bool) {
-	// We're teeing the form parsing into a sha1 HMAC so we can
-	// authenticate what we actually parsed (if we *secret is set,
-	// anyway)
+	// We're teeing the form parsing into sha1 and sha256 HMACs so
+	// we can authenticate what we actually parsed (if we *secret is
+	// set, anyway)
 	mac := hmac.New(sha1.New, []byte(*secret))
-	r := io.TeeReader(req.Body, mac)
+	mac256 := hmac.New(sha256.New, []byte(*secret))
+	r := io.TeeReader(req.Body, io.MultiWriter(mac, mac256))
 	payload, err := readPayload(r)
 	if err != nil {
 		http.Error(w, err.Error(), 500)
 		return
 	}
 
-	if !(*secret == "" || checkHMAC(mac, req.Header.Get("X-Hub-Signature"))) {
+	// The sha1 signature is checked only for hooks that
+	// don't send a sha256 one, and only if allowed.
+	authorized := *secret == ""
+	if sig := req.Header.Get("X-Hub-Signature-256"); sig != "" && !authorized {
+		authorized = checkHMAC256(mac256, sig)
+	} else if !authorized && !*requireSHA256 {
+		authorized = checkHMAC(mac, req.Header.Get("X-Hub-Signature"))
+	}
+
+	if !authorized {
 		http.Error(w, "not authorized", http.StatusUnauthorized)
 		return
 	}
@@ -281,6 +419,9 @@ func handleGitHubCallback(w http.ResponseWriter, req *http.Request, bg bool) {
 				Private  bool
 				Name     string
 				FullName string `json:"full_name"`
+				HtmlUrl  string `json:"html_url"`
+				CloneUrl string `json:"clone_url"`
+				SshUrl   string `json:"ssh_url"`
 			}
 		}{}
 
@@ -291,15 +432,167 @@ func handleGitHubCallback(w http.ResponseWriter, req *http.Request, bg bool) {
 		return
 	}
 
-	path := p.Repository.FullName
-	repo_path := p.Repository.FullName
-	private := p.Repository.Private
+	repo := p.Repository.CloneUrl
+	if p.Repository.Private {
+		repo = p.Repository.SshUrl
+	}
+	repo, err = githubCloneURL(repo, p.Repository.HtmlUrl)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
 
-	if exists(filepath.Join(*thePath, path)) {
-		doUpdate(w, path, bg, payload)
-	} else {
-		doCreate(w, path, repo_path, private, bg, payload)
+	doMirror(w, p.Repository.FullName, repo, bg, payload)
+}
+
+func handleGitLabCallback(w http.ResponseWriter, req *http.Request, bg bool) {
+	payload, err := readPayload(req.Body)
+	if err != nil {
+		http.Error(w, err.Error(), 500)
+		return
 	}
+
+	// GitLab sends the secret token as is
+	if !(*secret == "" || checkToken(*secret, req.Header.Get("X-Gitlab-Token"))) {
+		http.Error(w, "not authorized", http.StatusUnauthorized)
+		return
+	}
+
+	p := struct {
+			Project struct {
+				PathWithNamespace string `json:"path_with_namespace"`
+				GitHttpUrl        string `json:"git_http_url"`
+				GitSshUrl         string `json:"git_ssh_url"`
+				VisibilityLevel   int    `json:"visibility_level"`
+			}
+		}{}
+
+	err = json.Unmarshal(payload, &p)
+	if err != nil {
+		log.Printf("Error unmarshalling data: %v", err)
+		http.Error(w, "Error parsing JSON", http.StatusInternalServerError)
+		return
+	}
+
+	// only public projects can be cloned anonymously
+	repo := p.Project.GitSshUrl
+	if p.Project.VisibilityLevel == 20 {
+		repo = p.Project.GitHttpUrl
+	}
+
+	doMirror(w, p.Project.PathWithNamespace, repo, bg, payload)
+}
+
+func handleGiteaCallback(w http.ResponseWriter, req *http.Request, bg bool) {
+	mac := hmac.New(sha256.New, []byte(*secret))
+	r := io.TeeReader(req.Body, mac)
+	payload, err := readPayload(r)
+	if err != nil {
+		http.Error(w, err.Error(), 500)
+		return
+	}
+
+	if !(*secret == "" || checkSignature(mac, "", req.Header.Get("X-Gitea-Signature"))) {
+		http.Error(w, "not authorized", http.StatusUnauthorized)
+		return
+	}
+
+	p := struct {
+			Repository struct {
+				Private  bool
+				FullName string `json:"full_name"`
+				HtmlUrl  string `json:"html_url"`
+				CloneUrl string `json:"clone_url"`
+				SshUrl   string `json:"ssh_url"`
+			}
+		}{}
+
+	err = json.Unmarshal(payload, &p)
+	if err != nil {
+		log.Printf("Error unmarshalling data: %v", err)
+		http.Error(w, "Error parsing JSON", http.StatusInternalServerError)
+		return
+	}
+
+	repo := p.Repository.CloneUrl
+	if p.Repository.Private {
+		repo = p.Repository.SshUrl
+	}
+	repo, err = githubCloneURL(repo, p.Repository.HtmlUrl)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	doMirror(w, p.Repository.FullName, repo, bg, payload)
+}
+
+type bitbucketLink struct {
+	Name string
+	Href string
+}
+
+// bitbucketCloneURL picks the clone link of the given kind, "https"
+// or "ssh". Push payloads have only the web link, so it's derived
+// from that one otherwise, eg. git@bitbucket.org:foo/bar.git
+func bitbucketCloneURL(links []bitbucketLink, html string, name string) string {
+	for _, link := range links {
+		if link.Name == name {
+			return link.Href
+		}
+	}
+
+	u, err := url.Parse(html)
+	if err != nil || u.Host == "" {
+		return ""
+	}
+	path := strings.Trim(u.Path, "/")
+	if name == "ssh" {
+		return fmt.Sprintf("git@%v:%v.git", u.Hostname(), path)
+	}
+	return fmt.Sprintf("%v://%v/%v.git", u.Scheme, u.Host, path)
+}
+
+func handleBitbucketCallback(w http.ResponseWriter, req *http.Request, bg bool) {
+	mac := hmac.New(sha256.New, []byte(*secret))
+	r := io.TeeReader(req.Body, mac)
+	payload, err := readPayload(r)
+	if err != nil {
+		http.Error(w, err.Error(), 500)
+		return
+	}
+
+	if !(*secret == "" || checkSignature(mac, "sha256=", req.Header.Get("X-Hub-Signature"))) {
+		http.Error(w, "not authorized", http.StatusUnauthorized)
+		return
+	}
+
+	p := struct {
+			Repository struct {
+				FullName  string `json:"full_name"`
+				IsPrivate bool   `json:"is_private"`
+				Links     struct {
+					Html  struct{ Href string }
+					Clone []bitbucketLink
+				}
+			}
+		}{}
+
+	err = json.Unmarshal(payload, &p)
+	if err != nil {
+		log.Printf("Error unmarshalling data: %v", err)
+		http.Error(w, "Error parsing JSON", http.StatusInternalServerError)
+		return
+	}
+
+	kind := "https"
+	if p.Repository.IsPrivate {
+		kind = "ssh"
+	}
+	repo := bitbucketCloneURL(p.Repository.Links.Clone,
+		p.Repository.Links.Html.Href, kind)
+
+	doMirror(w, p.Repository.FullName, repo, bg, payload)
 }
 
 func handleReq(w http.ResponseWriter, req *http.Request) {
@@ -314,6 +607,12 @@ func handleReq(w http.ResponseWriter, req *http.Request) {
 		switch req.URL.Path {
 		case "/callback/github":
 			handleGitHubCallback(w, req, backgrounded)
+		case "/callback/gitlab":
+			handleGitLabCallback(w, req, backgrounded)
+		case "/callback/gitea":
+			handleGiteaCallback(w, req, backgrounded)
+		case "/callback/bitbucket":
+			handleBitbucketCallback(w, req, backgrounded)
 		default:
 			http.Error(w, "Path not found",
 				http.StatusNotFound)
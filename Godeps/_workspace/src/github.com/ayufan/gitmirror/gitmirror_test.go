@@ -3,27 +3,175 @@ package main
 import (
 	"crypto/hmac"
 	"crypto/sha1"
+	"crypto/sha256"
+	"hash"
 	"io"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
 	"testing"
 )
 
 func TestHMACCompare(t *testing.T) {
 	tests := []struct {
-		data string
-		hash string
-		eq   bool
+		alg   func() hash.Hash
+		check func(hash.Hash, string) bool
+		data  string
+		hash  string
+		eq    bool
 	}{
-		{"", "sha1=8a3b873f8dcaebf748c60464fb16878b2953d6df", true},
-		{"xxx", "sha1=950408a7db2d17330d8a288417c9d38fd8c6bfef", true},
-		{"xxx", "sha1=950408a7db2d17330d8a288417c9d38fd8c6bfee", false},
-		{"xxx", "", false},
+		{sha1.New, checkHMAC, "", "sha1=8a3b873f8dcaebf748c60464fb16878b2953d6df", true},
+		{sha1.New, checkHMAC, "xxx", "sha1=950408a7db2d17330d8a288417c9d38fd8c6bfef", true},
+		{sha1.New, checkHMAC, "xxx", "sha1=950408a7db2d17330d8a288417c9d38fd8c6bfee", false},
+		{sha1.New, checkHMAC, "xxx", "", false},
+		{sha256.New, checkHMAC256, "", "sha256=d5c41f3af7fec8eed2ff49eab74c54751bc596a20bac0b8b78a9945666feaa83", true},
+		{sha256.New, checkHMAC256, "xxx", "sha256=0a0fdc28e93892091c8791174fd72e888b7347d76af5172fd62311bbb0fdec9c", true},
+		{sha256.New, checkHMAC256, "xxx", "sha256=0a0fdc28e93892091c8791174fd72e888b7347d76af5172fd62311bbb0fdec9d", false},
+		{sha256.New, checkHMAC256, "xxx", "sha1=0a0fdc28e93892091c8791174fd72e888b7347d76af5172fd62311bbb0fdec9c", false},
+		{sha256.New, checkHMAC256, "xxx", "", false},
+		// a sha1 signature never passes for sha256
+		{sha1.New, checkHMAC256, "xxx", "sha1=950408a7db2d17330d8a288417c9d38fd8c6bfef", false},
 	}
 
 	for _, test := range tests {
-		h := hmac.New(sha1.New, []byte{'h', 'i'})
+		h := hmac.New(test.alg, []byte{'h', 'i'})
 		io.WriteString(h, test.data)
-		if checkHMAC(h, test.hash) != test.eq {
-			t.Errorf("On %q, expected %v, got %x", test.data, test.eq, h.Sum(nil))
+		if test.check(h, test.hash) != test.eq {
+			t.Errorf("On %q with %q, expected %v, got %x", test.data, test.hash, test.eq, h.Sum(nil))
+		}
+	}
+}
+
+func TestRepoHost(t *testing.T) {
+	tests := []struct {
+		repo string
+		exp  string
+	}{
+		{"https://github.com/foo/bar.git", "github.com"},
+		{"https://GitHub.com:443/foo/bar.git", "github.com"},
+		{"ssh://git@gitlab.example.com:2222/foo/bar.git", "gitlab.example.com"},
+		{"git@bitbucket.org:foo/bar.git", "bitbucket.org"},
+		{"gitea.example.com:foo/bar.git", "gitea.example.com"},
+		{"", ""},
+		{"foo/bar", ""},
+		{"https://../foo/bar.git", ""},
+		{"git@..:foo/bar.git", ""},
+	}
+
+	for _, test := range tests {
+		got := repoHost(test.repo)
+		if got != test.exp {
+			t.Errorf("On %q, expected %q, got %q", test.repo, test.exp, got)
+		}
+	}
+}
+
+func TestMirrorPath(t *testing.T) {
+	dir, err := ioutil.TempDir("", "gitmirror")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	defer func(path string) { *thePath = path }(*thePath)
+	*thePath = dir
+
+	// mirrors created before the host was part of the path
+	for path, origin := range map[string]string{
+		"foo/legacy": "https://github.com/foo/legacy.git",
+		"foo/other":  "https://gitlab.example.com/foo/other.git",
+	} {
+		cmd := exec.Command("git", "init", "-q", "--bare", filepath.Join(dir, path))
+		if out, err := cmd.CombinedOutput(); err != nil {
+			t.Fatalf("git init: %v\n%s", err, out)
+		}
+		cmd = exec.Command("git", "--git-dir="+filepath.Join(dir, path), "remote", "add", "origin", origin)
+		if out, err := cmd.CombinedOutput(); err != nil {
+			t.Fatalf("git remote add: %v\n%s", err, out)
+		}
+	}
+
+	tests := []struct {
+		path string
+		repo string
+		exp  string
+		err  bool
+	}{
+		{"foo/bar", "https://github.com/foo/bar.git", "github.com/foo/bar", false},
+		{"foo/bar", "git@gitlab.example.com:foo/bar.git", "gitlab.example.com/foo/bar", false},
+		{"foo/legacy", "git@github.com:foo/legacy.git", "foo/legacy", false},
+		{"foo/legacy", "", "foo/legacy", false},
+		// a mirror of the same name from another host isn't reused
+		{"foo/other", "https://github.com/foo/other.git", "github.com/foo/other", false},
+		{"foo/bar", "", "", true},
+		{"foo/bar", "foo/bar", "", true},
+	}
+
+	for _, test := range tests {
+		got, err := mirrorPath(test.path, test.repo)
+		if (err != nil) != test.err || got != filepath.FromSlash(test.exp) {
+			t.Errorf("On %v from %q, expected %q/%v, got %q/%v", test.path, test.repo, test.exp, test.err, got, err)
+		}
+	}
+}
+
+func TestValidCloneURL(t *testing.T) {
+	tests := []struct {
+		repo string
+		exp  bool
+	}{
+		{"https://github.com/foo/bar.git", true},
+		{"ssh://git@gitlab.example.com:2222/foo/bar.git", true},
+		{"git@bitbucket.org:foo/bar.git", true},
+		{"gitea.example.com:foo/bar.git", true},
+		{"", false},
+		{"http://github.com/foo/bar.git", false},
+		{"git://github.com/foo/bar.git", false},
+		{"file:///etc/passwd", false},
+		{"/tmp/foo/bar.git", false},
+		{"../foo/bar.git", false},
+		{"ext::sh -c touch% /tmp/pwned", false},
+		{"-uhttps://github.com/foo/bar.git", false},
+		{"--upload-pack=touch /tmp/pwned", false},
+		{"-oProxyCommand=x:foo/bar.git", false},
+		{"git@-oProxyCommand=x:foo/bar.git", false},
+	}
+
+	for _, test := range tests {
+		got := validCloneURL(test.repo)
+		if got != test.exp {
+			t.Errorf("On %q, expected %v, got %v", test.repo, test.exp, got)
+		}
+	}
+}
+
+func TestGitHubCloneURL(t *testing.T) {
+	defer func(s string) { *secret = s }(*secret)
+
+	tests := []struct {
+		secret string
+		repo   string
+		html   string
+		exp    bool
+	}{
+		{"", "https://github.com/foo/bar.git", "https://github.com/foo/bar", true},
+		{"", "git@github.com:foo/bar.git", "", true},
+		{"", "", "https://github.com/foo/bar", true},
+		{"", "https://internal.example.com/foo/bar.git", "https://internal.example.com/foo/bar", false},
+		{"hi", "https://internal.example.com/foo/bar.git", "https://internal.example.com/foo/bar", true},
+		{"hi", "git@internal.example.com:foo/bar.git", "https://internal.example.com/foo/bar", true},
+		{"hi", "https://internal.example.com/foo/bar.git", "https://github.example.com/foo/bar", false},
+		{"hi", "https://internal.example.com/foo/bar.git", "", false},
+		{"hi", "https://github.com/foo/bar.git", "", true},
+	}
+
+	for _, test := range tests {
+		*secret = test.secret
+		got, err := githubCloneURL(test.repo, test.html)
+		if (err == nil) != test.exp || (err == nil && got != test.repo) {
+			t.Errorf("On %q of %q with secret %q, expected %v, got %q/%v",
+				test.repo, test.html, test.secret, test.exp, got, err)
 		}
 	}
 }
@@ -0,0 +1,28 @@
+package apiutil
+
+import (
+	"strings"
+)
+
+// ParseLink maps relations of a Link header to their URLs,
+// eg. next to the URL of the following page.
+func ParseLink(s string) map[string]string {
+	rv := map[string]string{}
+	if s == "" {
+		return rv
+	}
+	for _, link := range strings.Split(s, ",") {
+		parts := strings.Split(strings.TrimSpace(link), ";")
+		if len(parts) < 2 {
+			continue
+		}
+		u := strings.Trim(strings.TrimSpace(parts[0]), "<>")
+		for _, param := range parts[1:] {
+			param = strings.TrimSpace(param)
+			if strings.HasPrefix(param, `rel="`) {
+				rv[strings.Trim(param[4:], `"`)] = u
+			}
+		}
+	}
+	return rv
+}
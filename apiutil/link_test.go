@@ -0,0 +1,31 @@
+package apiutil
+
+import (
+	"testing"
+)
+
+func TestParseLink(t *testing.T) {
+	tests := []struct {
+		link string
+		rel  string
+		exp  string
+	}{
+		{"", "next", ""},
+		{`<https://gitlab.example.com/api/v4/groups?page=2>; rel="next"`, "next",
+			"https://gitlab.example.com/api/v4/groups?page=2"},
+		{`<https://x/?page=1>; rel="first", <https://x/?page=3>; rel="next", <https://x/?page=9>; rel="last"`, "next",
+			"https://x/?page=3"},
+		{`<https://x/?page=1>; rel="first", <https://x/?page=9>; rel="last"`, "last",
+			"https://x/?page=9"},
+		{`<https://x/?page=1>; rel="first"`, "next", ""},
+		{`<https://x/?page=2>`, "next", ""},
+		{`garbage`, "next", ""},
+	}
+
+	for _, test := range tests {
+		got := ParseLink(test.link)[test.rel]
+		if got != test.exp {
+			t.Errorf("On %q rel %v, expected %q, got %q", test.link, test.rel, test.exp, got)
+		}
+	}
+}
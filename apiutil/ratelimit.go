@@ -0,0 +1,44 @@
+package apiutil
+
+import (
+	"log"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// RateLimit delays requests once a response reported
+// that the rate limit was used up.
+type RateLimit struct {
+	lock  sync.Mutex
+	reset time.Time
+}
+
+// Track remembers when the rate limit is lifted
+// if the response used it up.
+func (l *RateLimit) Track(header http.Header) {
+	reset, ok := RateLimitReset(header)
+	if !ok {
+		return
+	}
+
+	l.lock.Lock()
+	defer l.lock.Unlock()
+	l.reset = reset
+}
+
+// Wait sleeps until the rate limit is lifted, but at most max.
+func (l *RateLimit) Wait(max time.Duration) {
+	l.lock.Lock()
+	wait := l.reset.Sub(time.Now())
+	l.lock.Unlock()
+
+	if wait <= 0 {
+		return
+	}
+	if wait > max {
+		wait = max
+	}
+	log.Printf("Rate limit reached, waiting %v...", wait)
+	time.Sleep(wait)
+}
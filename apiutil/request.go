@@ -0,0 +1,112 @@
+package apiutil
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+
+	"github.com/dustin/httputil"
+)
+
+// Error describes a failed API request.
+type Error struct {
+	Method string
+	URL    string
+	Err    error
+}
+
+// Error satisfies the "error" interface.
+func (e *Error) Error() string {
+	return fmt.Sprintf("couldn't execute %v against %v: %v", e.Method, e.URL, e.Err)
+}
+
+// HasStatus returns true if the request failed with one of the statuses.
+func HasStatus(err error, statuses ...int) bool {
+	if e, ok := err.(*Error); ok {
+		err = e.Err
+	}
+	for _, status := range statuses {
+		if httputil.IsHTTPStatus(err, status) {
+			return true
+		}
+	}
+	return false
+}
+
+// Request is a JSON API request that can be sent repeatedly.
+type Request struct {
+	Method string
+	URL    string
+
+	// Header is sent with every attempt, eg. for authentication.
+	Header http.Header
+
+	// Data is the encoded body, if any.
+	Data []byte
+
+	// Status is the status of a successful response.
+	Status int
+
+	// Result receives the decoded response unless nil.
+	Result interface{}
+}
+
+// NewRequest creates a request with body encoded as JSON.
+func NewRequest(method string, url string, body interface{}, status int, result interface{}) (*Request, error) {
+	req := &Request{
+		Method: method,
+		URL:    url,
+		Header: http.Header{},
+		Status: status,
+		Result: result,
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if body != nil {
+		data, err := json.Marshal(body)
+		if err != nil {
+			return nil, fmt.Errorf("failed to marshal request: %v", err)
+		}
+		req.Data = data
+	}
+	return req, nil
+}
+
+// Send makes a single attempt at the request. It returns the response
+// headers and whether a failed attempt is worth repeating. Failures
+// are returned as *Error.
+func (r *Request) Send(client *http.Client) (http.Header, bool, error) {
+	var reader io.Reader
+	if r.Data != nil {
+		reader = bytes.NewReader(r.Data)
+	}
+
+	req, err := http.NewRequest(r.Method, r.URL, reader)
+	if err != nil {
+		return nil, false, err
+	}
+	for key, values := range r.Header {
+		req.Header[key] = values
+	}
+
+	res, err := client.Do(req)
+	if err != nil {
+		return nil, IsRetryableError(r.Method, err), &Error{Method: r.Method, URL: r.URL, Err: err}
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode != r.Status {
+		err = &Error{Method: r.Method, URL: r.URL, Err: httputil.HTTPError(res)}
+		return res.Header, IsRetryableStatus(r.Method, res.StatusCode), err
+	}
+
+	if r.Result != nil {
+		err = json.NewDecoder(res.Body).Decode(r.Result)
+		if err != nil {
+			return res.Header, false, &Error{Method: r.Method, URL: r.URL,
+				Err: fmt.Errorf("error decoding json payload: %v", err)}
+		}
+	}
+	return res.Header, false, nil
+}
@@ -0,0 +1,68 @@
+package apiutil
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestRequestSend(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "token secret" {
+			t.Errorf("Expected token, got %q", r.Header.Get("Authorization"))
+		}
+		switch r.URL.Path {
+		case "/echo":
+			data, _ := ioutil.ReadAll(r.Body)
+			w.WriteHeader(http.StatusCreated)
+			w.Write(data)
+		case "/unavailable":
+			http.Error(w, "unavailable", http.StatusServiceUnavailable)
+		case "/invalid":
+			w.Write([]byte("{"))
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer server.Close()
+
+	tests := []struct {
+		method string
+		path   string
+		retry  bool
+		status int
+	}{
+		{"POST", "/echo", false, 0},
+		{"GET", "/missing", false, 404},
+		{"GET", "/unavailable", true, 503},
+		{"POST", "/unavailable", false, 503},
+		{"GET", "/invalid", false, 0},
+	}
+
+	for _, test := range tests {
+		var result map[string]string
+		req, err := NewRequest(test.method, server.URL+test.path, map[string]string{"name": "foo"}, 201, &result)
+		if err != nil {
+			t.Fatal(err)
+		}
+		req.Header.Set("Authorization", "token secret")
+
+		_, retry, err := req.Send(server.Client())
+		if test.path == "/echo" {
+			if err != nil || result["name"] != "foo" {
+				t.Errorf("On %v %v, expected foo, got %v/%v", test.method, test.path, result, err)
+			}
+			continue
+		}
+		if _, ok := err.(*Error); !ok {
+			t.Errorf("On %v %v, expected *Error, got %v", test.method, test.path, err)
+		}
+		if retry != test.retry {
+			t.Errorf("On %v %v, expected retry %v, got %v", test.method, test.path, test.retry, retry)
+		}
+		if test.status != 0 && !HasStatus(err, test.status) {
+			t.Errorf("On %v %v, expected status %v, got %v", test.method, test.path, test.status, err)
+		}
+	}
+}
@@ -0,0 +1,147 @@
+// Package apiutil has the parts shared by the API clients: retries
+// with backoff and parsing of pagination headers.
+package apiutil
+
+import (
+	"errors"
+	"log"
+	"math/rand"
+	"net"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// Retry configures how failed requests are repeated.
+type Retry struct {
+	// MaxRetries is the number of times a request is repeated after
+	// a network error, a server error or being rate limited.
+	MaxRetries int
+
+	// RetryWait is the initial delay between attempts. It doubles
+	// with every attempt up to MaxRetryWait. Delays requested by
+	// the server through Retry-After or RateLimit-Reset are capped
+	// by MaxRetryWait as well.
+	RetryWait    time.Duration
+	MaxRetryWait time.Duration
+}
+
+// DefaultRetry is used by new clients.
+var DefaultRetry = Retry{
+	MaxRetries:   3,
+	RetryWait:    time.Second,
+	MaxRetryWait: 30 * time.Second,
+}
+
+// Do calls attempt until it succeeds, fails for good or runs out of
+// retries. Attempt returns the response headers, if any, and whether
+// its failure is worth repeating.
+func (r *Retry) Do(method string, url string, attempt func() (http.Header, bool, error)) (http.Header, error) {
+	var err error
+	for i := 0; ; i++ {
+		var header http.Header
+		var retry bool
+		header, retry, err = attempt()
+		if err == nil {
+			return header, nil
+		} else if !retry || i >= r.MaxRetries {
+			break
+		}
+
+		wait := r.Wait(i, header)
+		log.Printf("Retrying %v to %v in %v: %v", method, url, wait, err)
+		time.Sleep(wait)
+	}
+	return nil, err
+}
+
+// Wait returns how long to wait before the attempt following
+// the given one, preferring what the server asked for.
+func (r *Retry) Wait(attempt int, header http.Header) time.Duration {
+	wait := ServerRetryWait(header, time.Now())
+	if wait <= 0 {
+		wait = r.RetryWait << uint(attempt)
+		if wait <= 0 || wait > r.MaxRetryWait {
+			wait = r.MaxRetryWait
+		}
+		// jitter spreads retries of concurrent mirrors
+		wait = wait/2 + time.Duration(rand.Int63n(int64(wait/2)+1))
+	}
+	if wait > r.MaxRetryWait {
+		wait = r.MaxRetryWait
+	}
+	return wait
+}
+
+// isIdempotent returns true for methods that can be repeated
+// without changing the result, unlike POST.
+func isIdempotent(method string) bool {
+	switch method {
+	case "GET", "HEAD", "PUT", "DELETE", "OPTIONS":
+		return true
+	}
+	return false
+}
+
+// IsRetryableStatus returns true for statuses of throttled requests
+// and, if the request can be repeated safely, temporary server
+// failures. The server could have handled a POST before failing.
+func IsRetryableStatus(method string, status int) bool {
+	switch status {
+	case 429:
+		return true
+	case 500, 502, 503, 504:
+		return isIdempotent(method)
+	}
+	return false
+}
+
+// IsRetryableError returns true for network errors of requests that
+// can be repeated safely. Other requests are repeated only if they
+// failed to connect, so the server never got them.
+func IsRetryableError(method string, err error) bool {
+	if isIdempotent(method) {
+		return true
+	}
+	var op_err *net.OpError
+	return errors.As(err, &op_err) && op_err.Op == "dial"
+}
+
+// ServerRetryWait reads the delay requested through Retry-After
+// or, when the rate limit is used up, through RateLimit-Reset.
+func ServerRetryWait(header http.Header, now time.Time) time.Duration {
+	if header == nil {
+		return 0
+	}
+
+	if value := header.Get("Retry-After"); value != "" {
+		if seconds, err := strconv.Atoi(value); err == nil {
+			return time.Duration(seconds) * time.Second
+		}
+		if date, err := http.ParseTime(value); err == nil {
+			return date.Sub(now)
+		}
+	}
+
+	if reset, ok := RateLimitReset(header); ok {
+		return reset.Sub(now)
+	}
+	return 0
+}
+
+// RateLimitReset returns when the rate limit is lifted if all
+// requests allowed by it were already used. GitHub sends the
+// headers with an X- prefix.
+func RateLimitReset(header http.Header) (time.Time, bool) {
+	for _, prefix := range []string{"", "X-"} {
+		if header.Get(prefix+"RateLimit-Remaining") != "0" {
+			continue
+		}
+		reset, err := strconv.ParseInt(header.Get(prefix+"RateLimit-Reset"), 10, 64)
+		if err != nil {
+			continue
+		}
+		return time.Unix(reset, 0), true
+	}
+	return time.Time{}, false
+}
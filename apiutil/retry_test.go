@@ -0,0 +1,180 @@
+package apiutil
+
+import (
+	"errors"
+	"net"
+	"net/http"
+	"net/url"
+	"testing"
+	"time"
+)
+
+func TestRateLimitReset(t *testing.T) {
+	tests := []struct {
+		header http.Header
+		exp    time.Time
+		ok     bool
+	}{
+		{http.Header{}, time.Time{}, false},
+		{http.Header{"Ratelimit-Remaining": {"0"}, "Ratelimit-Reset": {"1500000000"}},
+			time.Unix(1500000000, 0), true},
+		{http.Header{"Ratelimit-Remaining": {"5"}, "Ratelimit-Reset": {"1500000000"}},
+			time.Time{}, false},
+		{http.Header{"Ratelimit-Remaining": {"0"}, "Ratelimit-Reset": {"soon"}},
+			time.Time{}, false},
+		{http.Header{"Ratelimit-Remaining": {"0"}}, time.Time{}, false},
+		{http.Header{"X-Ratelimit-Remaining": {"0"}, "X-Ratelimit-Reset": {"1500000000"}},
+			time.Unix(1500000000, 0), true},
+		{http.Header{"X-Ratelimit-Remaining": {"4999"}, "X-Ratelimit-Reset": {"1500000000"}},
+			time.Time{}, false},
+	}
+
+	for _, test := range tests {
+		got, ok := RateLimitReset(test.header)
+		if ok != test.ok || !got.Equal(test.exp) {
+			t.Errorf("On %v, expected %v/%v, got %v/%v", test.header, test.exp, test.ok, got, ok)
+		}
+	}
+}
+
+func TestServerRetryWait(t *testing.T) {
+	now := time.Unix(1500000000, 0)
+
+	tests := []struct {
+		header http.Header
+		exp    time.Duration
+	}{
+		{nil, 0},
+		{http.Header{}, 0},
+		{http.Header{"Retry-After": {"7"}}, 7 * time.Second},
+		{http.Header{"Retry-After": {now.Add(time.Minute).UTC().Format(http.TimeFormat)}}, time.Minute},
+		{http.Header{"Retry-After": {"later"}}, 0},
+		{http.Header{"Ratelimit-Remaining": {"0"}, "Ratelimit-Reset": {"1500000030"}}, 30 * time.Second},
+		{http.Header{"Ratelimit-Remaining": {"1"}, "Ratelimit-Reset": {"1500000030"}}, 0},
+		// Retry-After takes precedence
+		{http.Header{"Retry-After": {"2"}, "Ratelimit-Remaining": {"0"}, "Ratelimit-Reset": {"1500000030"}},
+			2 * time.Second},
+	}
+
+	for _, test := range tests {
+		got := ServerRetryWait(test.header, now)
+		if got != test.exp {
+			t.Errorf("On %v, expected %v, got %v", test.header, test.exp, got)
+		}
+	}
+}
+
+func TestRetryWait(t *testing.T) {
+	r := &Retry{RetryWait: time.Second, MaxRetryWait: 10 * time.Second}
+
+	tests := []struct {
+		attempt int
+		header  http.Header
+		min     time.Duration
+		max     time.Duration
+	}{
+		{0, nil, 500 * time.Millisecond, time.Second},
+		{1, nil, time.Second, 2 * time.Second},
+		{3, nil, 4 * time.Second, 8 * time.Second},
+		{4, nil, 5 * time.Second, 10 * time.Second},
+		// the shift overflows
+		{100, nil, 5 * time.Second, 10 * time.Second},
+		{0, http.Header{"Retry-After": {"3"}}, 3 * time.Second, 3 * time.Second},
+		{0, http.Header{"Retry-After": {"3600"}}, 10 * time.Second, 10 * time.Second},
+	}
+
+	for _, test := range tests {
+		for i := 0; i < 20; i++ {
+			got := r.Wait(test.attempt, test.header)
+			if got < test.min || got > test.max {
+				t.Errorf("On attempt %v with %v, expected %v-%v, got %v",
+					test.attempt, test.header, test.min, test.max, got)
+				break
+			}
+		}
+	}
+}
+
+func TestRetryDo(t *testing.T) {
+	r := &Retry{MaxRetries: 2, RetryWait: time.Millisecond, MaxRetryWait: time.Millisecond}
+	failure := errors.New("failure")
+
+	tests := []struct {
+		failures int
+		retry    bool
+		attempts int
+		err      error
+	}{
+		{0, true, 1, nil},
+		{2, true, 3, nil},
+		{3, true, 3, failure},
+		{1, false, 1, failure},
+	}
+
+	for _, test := range tests {
+		attempts := 0
+		_, err := r.Do("GET", "https://x/", func() (http.Header, bool, error) {
+			attempts++
+			if attempts <= test.failures {
+				return nil, test.retry, failure
+			}
+			return http.Header{}, false, nil
+		})
+		if err != test.err || attempts != test.attempts {
+			t.Errorf("On %v failures, expected %v attempts and %v, got %v and %v",
+				test.failures, test.attempts, test.err, attempts, err)
+		}
+	}
+}
+
+func TestIsRetryableStatus(t *testing.T) {
+	tests := []struct {
+		method string
+		status int
+		exp    bool
+	}{
+		{"GET", 200, false},
+		{"GET", 404, false},
+		{"GET", 429, true},
+		{"GET", 502, true},
+		{"PUT", 503, true},
+		{"DELETE", 500, true},
+		// the server could have created the resource already
+		{"POST", 500, false},
+		{"POST", 502, false},
+		{"POST", 429, true},
+	}
+
+	for _, test := range tests {
+		got := IsRetryableStatus(test.method, test.status)
+		if got != test.exp {
+			t.Errorf("On %v with %v, expected %v, got %v", test.method, test.status, test.exp, got)
+		}
+	}
+}
+
+func TestIsRetryableError(t *testing.T) {
+	dial := &url.Error{Op: "Post", URL: "https://x/", Err: &net.OpError{Op: "dial", Net: "tcp", Err: errors.New("connection refused")}}
+	read := &url.Error{Op: "Post", URL: "https://x/", Err: &net.OpError{Op: "read", Net: "tcp", Err: errors.New("connection reset by peer")}}
+	timeout := &url.Error{Op: "Post", URL: "https://x/", Err: errors.New("net/http: request canceled (Client.Timeout exceeded while awaiting headers)")}
+
+	tests := []struct {
+		method string
+		err    error
+		exp    bool
+	}{
+		{"GET", dial, true},
+		{"GET", read, true},
+		{"PUT", timeout, true},
+		{"POST", dial, true},
+		{"POST", read, false},
+		{"POST", timeout, false},
+	}
+
+	for _, test := range tests {
+		got := IsRetryableError(test.method, test.err)
+		if got != test.exp {
+			t.Errorf("On %v with %v, expected %v, got %v", test.method, test.err, test.exp, got)
+		}
+	}
+}
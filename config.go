@@ -0,0 +1,62 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+)
+
+// Config is read from the JSON file given with -config.
+type Config struct {
+	// Rules map upstream repositories to GitLab projects.
+	// The first matching rule wins.
+	Rules []*mappingRule `json:"rules"`
+
+	// Project holds defaults for created projects.
+	Project *projectSettings `json:"project"`
+
+	// SSHHostKeys are known_hosts lines trusted for pushes.
+	SSHHostKeys []string `json:"ssh_host_keys"`
+
+	// IncludeRefs and ExcludeRefs select the mirrored refs.
+	IncludeRefs []string `json:"include_refs"`
+	ExcludeRefs []string `json:"exclude_refs"`
+
+	// Targets are GitLab instances the repository is mirrored to.
+	Targets []*gitlabTarget `json:"targets"`
+}
+
+func loadConfig(path string) (*Config, error) {
+	config := &Config{}
+	if path == "" {
+		return config, nil
+	}
+
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	err = json.Unmarshal(data, config)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse %v: %v", path, err)
+	}
+
+	err = config.Project.validate()
+	if err != nil {
+		return nil, fmt.Errorf("invalid project settings in %v: %v", path, err)
+	}
+
+	err = validateTargets(config.Targets)
+	if err != nil {
+		return nil, fmt.Errorf("invalid targets in %v: %v", path, err)
+	}
+
+	for i, rule := range config.Rules {
+		err = rule.compile()
+		if err != nil {
+			return nil, fmt.Errorf("invalid rule %d in %v: %v", i+1, path, err)
+		}
+	}
+	return config, nil
+}
@@ -0,0 +1,111 @@
+package main
+
+import (
+	"fmt"
+	"io/ioutil"
+	"log"
+	"os"
+	"path/filepath"
+	"strconv"
+	"time"
+
+	"github.com/ayufan/gitlab-mirror-post-fetch/gitlab"
+)
+
+func exists(path string) bool {
+	_, err := os.Stat(path)
+	return err == nil
+}
+
+// deployKeyPath is the private key used to push to the remote.
+// The public key is stored next to it with .pub suffix.
+func deployKeyPath() string {
+	return filepath.Join(stateDir(), *gitlab_remote+".deploy_key")
+}
+
+func writeFileAtomic(path string, data []byte, perm os.FileMode) error {
+	tmp := path + ".tmp"
+	err := ioutil.WriteFile(tmp, data, perm)
+	if err != nil {
+		return err
+	}
+	return os.Rename(tmp, path)
+}
+
+// registerDeployKey generates a new key pair, registers it as a
+// write-enabled deploy key of the project and stores it in the mirror.
+// It returns the id of the previous key, if there was one.
+func registerDeployKey(project_data *gitlab.Project) (int, error) {
+	comment := fmt.Sprintf("gitlab-mirror@%v", project_data.PathWithNamespace)
+	private_key, public_key, err := generateSSHKey(comment)
+	if err != nil {
+		return 0, fmt.Errorf("failed to generate key: %v", err)
+	}
+
+	key := gitlab.DeployKey{
+		Title:   fmt.Sprintf("gitlab-mirror %v", time.Now().Format("2006-01-02 15:04")),
+		Key:     public_key,
+		CanPush: true,
+	}
+	created, err := client.AddDeployKey(project_data.Id, key)
+	if err != nil {
+		return 0, fmt.Errorf("failed to register deploy key: %v", err)
+	}
+
+	err = os.MkdirAll(stateDir(), 0700)
+	if err != nil {
+		return 0, err
+	}
+	err = writeFileAtomic(deployKeyPath()+".pub", []byte(public_key+"\n"), 0644)
+	if err != nil {
+		return 0, err
+	}
+	err = writeFileAtomic(deployKeyPath(), private_key, 0600)
+	if err != nil {
+		return 0, err
+	}
+
+	id_key := remoteConfigKey(*gitlab_remote, "deploy-key-id")
+	previous, _ := strconv.Atoi(getGitConfig(id_key))
+	err = setGitConfig(id_key, strconv.Itoa(created.Id))
+	if err != nil {
+		return 0, fmt.Errorf("failed to store deploy key id: %v", err)
+	}
+	return previous, nil
+}
+
+// doEnsureDeployKey creates the deploy key of the mirror
+// if there is none yet.
+func doEnsureDeployKey() {
+	if !*deploy_keys || *push_transport != "ssh" || exists(deployKeyPath()) {
+		return
+	}
+
+	project_data := doFindRemoteProject()
+	log.Printf("Creating deploy key for %v...", project_data.PathWithNamespace)
+	_, err := registerDeployKey(project_data)
+	if err != nil {
+		log.Fatalf("Failed to create deploy key for %v: %v", project_data.PathWithNamespace, err)
+	}
+}
+
+// doRotateKeys replaces the deploy key of the mirror. The new key is
+// in place before the old one is removed, so pushes keep working.
+func doRotateKeys() {
+	project_data := doFindRemoteProject()
+	log.Printf("Rotating deploy key of %v...", project_data.PathWithNamespace)
+	previous, err := registerDeployKey(project_data)
+	if err != nil {
+		log.Fatalf("Failed to rotate deploy key of %v: %v", project_data.PathWithNamespace, err)
+	}
+
+	if previous == 0 {
+		return
+	}
+
+	log.Printf("Removing previous deploy key %v...", previous)
+	err = client.DeleteDeployKey(project_data.Id, previous)
+	if err != nil && !gitlab.IsNotFound(err) {
+		log.Fatalf("Failed to remove previous deploy key of %v: %v", project_data.PathWithNamespace, err)
+	}
+}
@@ -0,0 +1,100 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"net/url"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+)
+
+// credentialHelper answers git with the push credentials passed
+// through the environment, so they are never written to disk.
+const credentialHelper = `!f() { test "$1" = get && echo "username=$GITLAB_MIRROR_PUSH_USERNAME" && echo "password=$GITLAB_MIRROR_PUSH_TOKEN"; }; f`
+
+// gitlabCommand prepares a git command talking to the GitLab remote
+// with credentials of the push transport.
+func gitlabCommand(args ...string) *exec.Cmd {
+	var options []string
+	env := os.Environ()
+
+	if *push_transport == "ssh" {
+		env = append(env, "GIT_SSH_COMMAND="+sshCommand())
+	} else if *push_transport == "https" {
+		// the empty helper drops helpers configured elsewhere
+		options = append(options, "-c", "credential.helper=", "-c", "credential.helper="+credentialHelper)
+		env = append(env,
+			"GITLAB_MIRROR_PUSH_USERNAME="+*push_username,
+			"GITLAB_MIRROR_PUSH_TOKEN="+pushToken(),
+			"GIT_TERMINAL_PROMPT=0")
+	}
+
+	cmd := exec.Command(*git, append(options, args...)...)
+	cmd.Env = env
+	return cmd
+}
+
+func pushToken() string {
+	if *push_token != "" {
+		return *push_token
+	}
+	return *private_token
+}
+
+// readRemoteURL parses the URL of the remote. The scp-like syntax,
+// eg. git@github.com:foo/bar.git, is turned into an ssh:// URL.
+func readRemoteURL(remote string) (*url.URL, error) {
+	out, err := exec.Command(*git, "config", fmt.Sprintf("remote.%v.url", remote)).Output()
+	if err != nil {
+		return nil, fmt.Errorf("no URL defined for %v", remote)
+	}
+
+	rawurl := strings.TrimSpace(string(out))
+	if !strings.Contains(rawurl, "://") {
+		rawurl = strings.Replace(rawurl, ":", "/", 1)
+		rawurl = "ssh://" + rawurl
+	}
+
+	result, err := url.Parse(rawurl)
+	if err != nil {
+		return nil, fmt.Errorf("invalid URL for %v: %s", remote, out)
+	}
+	return result, nil
+}
+
+func gitDir() string {
+	out, err := exec.Command(*git, "rev-parse", "--absolute-git-dir").Output()
+	if err != nil {
+		log.Fatalf("Failed to find git directory: %v", err)
+	}
+	return strings.TrimSpace(string(out))
+}
+
+// stateDir keeps files of the tool inside of the mirror.
+func stateDir() string {
+	return filepath.Join(gitDir(), "gitlab-mirror")
+}
+
+// remoteConfigKey names a git config entry describing the remote,
+// eg. gitlab-mirror.gitlab.deploy-key-id
+func remoteConfigKey(remote string, name string) string {
+	return fmt.Sprintf("gitlab-mirror.%v.%v", remote, name)
+}
+
+func getGitConfig(key string) string {
+	out, err := exec.Command(*git, "config", "--get", key).Output()
+	if err != nil {
+		return ""
+	}
+	return strings.TrimSpace(string(out))
+}
+
+func setGitConfig(key string, value string) error {
+	return exec.Command(*git, "config", key, value).Run()
+}
+
+func unsetGitConfig(key string) error {
+	return exec.Command(*git, "config", "--unset", key).Run()
+}
@@ -0,0 +1,146 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"strings"
+
+	"github.com/ayufan/gitlab-mirror-post-fetch/gitea"
+)
+
+// giteaProvider mirrors to Gitea or Forgejo. Organizations take the
+// place of groups, there are no subgroups.
+type giteaProvider struct {
+	client *gitea.Client
+}
+
+func newGiteaProvider() provider {
+	gitea_client := gitea.NewClient(*address, *private_token)
+	gitea_client.HTTPClient.Timeout = *timeout
+	gitea_client.MaxRetries = *retries
+	gitea_client.RetryWait = *retry_wait
+	gitea_client.MaxRetryWait = *max_retry_wait
+	log.Printf("Using Gitea API at %v...", gitea_client.URL+gitea.APIPath)
+	return &giteaProvider{client: gitea_client}
+}
+
+func giteaProject(repo *gitea.Repository) *mirrorProject {
+	return &mirrorProject{
+		Id:      repo.Id,
+		Path:    repo.FullName,
+		SshURL:  repo.SshURL,
+		HttpURL: repo.CloneURL,
+	}
+}
+
+// giteaEnabled maps access levels of project settings to Gitea,
+// which can only turn features on or off.
+func giteaEnabled(access_level string) *bool {
+	if access_level == "" {
+		return nil
+	}
+	enabled := access_level != "disabled"
+	return &enabled
+}
+
+func (p *giteaProvider) FindNamespace(path string) (*mirrorNamespace, error) {
+	if path == "" {
+		return nil, nil
+	}
+	if strings.Contains(path, "/") {
+		return nil, fmt.Errorf("subgroups are not supported by Gitea: %v", path)
+	}
+
+	log.Printf("Looking for organization %v...", path)
+	org, err := p.client.GetOrganization(path)
+	if gitea.IsNotFound(err) {
+		return nil, fmt.Errorf("no organization %v found", path)
+	} else if err != nil {
+		return nil, err
+	}
+	return &mirrorNamespace{Id: org.Id, Name: org.FullName, Path: org.Username}, nil
+}
+
+func (p *giteaProvider) owner(namespace *mirrorNamespace) (string, error) {
+	if namespace != nil {
+		return namespace.Path, nil
+	}
+	user, err := p.client.CurrentUser()
+	if err != nil {
+		return "", err
+	}
+	return user.Username, nil
+}
+
+// FindProject looks up the repository by path. A repository using
+// that path that doesn't record the target as its upstream belongs
+// to someone else, so the collision path is tried next. Unlike GitLab
+// projects, repositories without a recorded upstream are never taken
+// over, as the first push would prune their refs.
+func (p *giteaProvider) FindProject(namespace *mirrorNamespace, target *mirrorTarget) (*mirrorProject, error) {
+	owner, err := p.owner(namespace)
+	if err != nil {
+		return nil, err
+	}
+
+	paths := []string{target.Path, target.collisionPath()}
+	for _, path := range paths {
+		repo, err := p.client.GetRepository(owner, path)
+		if gitea.IsNotFound(err) {
+			target.Path = path
+			return nil, nil
+		} else if err != nil {
+			return nil, err
+		}
+
+		upstream := descriptionUpstream(repo.Description)
+		if strings.EqualFold(upstream, target.Upstream) {
+			return giteaProject(repo), nil
+		} else if upstream == "" {
+			upstream = "an unknown repository"
+		}
+		log.Printf("Repository %v is already used by %v...", repo.FullName, upstream)
+	}
+	return nil, fmt.Errorf("paths %v are already used in %v", strings.Join(paths, ", "), owner)
+}
+
+func (p *giteaProvider) CreateProject(namespace *mirrorNamespace, target *mirrorTarget) (*mirrorProject, error) {
+	org := ""
+	if namespace != nil {
+		org = namespace.Path
+	}
+
+	log.Printf("Creating repository %v in %v...", target.Path, namespaceName(namespace))
+	repo, err := p.client.CreateRepository(org, gitea.CreateRepository{
+		Name:          target.Path,
+		Description:   markDescription(target.Description, target.Upstream),
+		Private:       *visibility_level != "public",
+		DefaultBranch: target.Settings.DefaultBranch,
+	})
+	if gitea.IsAlreadyExists(err) {
+		// a retried request could have created it already
+		return p.FindProject(namespace, target)
+	} else if err != nil {
+		return nil, err
+	}
+
+	edit := gitea.EditRepository{
+		HasIssues:       giteaEnabled(target.Settings.Issues),
+		HasWiki:         giteaEnabled(target.Settings.Wiki),
+		HasPullRequests: giteaEnabled(target.Settings.MergeRequests),
+	}
+	if edit.HasIssues != nil || edit.HasWiki != nil || edit.HasPullRequests != nil {
+		repo, err = p.client.EditRepository(repo.Owner.Username, repo.Name, edit)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return giteaProject(repo), nil
+}
+
+func (p *giteaProvider) PushURL(project *mirrorProject) string {
+	if *push_transport == "https" {
+		return project.HttpURL
+	}
+	return project.SshURL
+}
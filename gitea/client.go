@@ -0,0 +1,67 @@
+// Package gitea is a small client for the parts of the Gitea and
+// Forgejo API needed to look up and create mirror repositories.
+package gitea
+
+import (
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/ayufan/gitlab-mirror-post-fetch/apiutil"
+)
+
+const APIPath = "/api/v1"
+
+// Client talks to a single Gitea instance on behalf of one user.
+type Client struct {
+	// URL is the address of the Gitea instance,
+	// e.g. https://gitea.example.com/
+	URL string
+
+	Token string
+
+	// HTTPClient is used for all requests. Its Timeout applies
+	// to every attempt separately.
+	HTTPClient *http.Client
+
+	// Retry controls how failed requests are repeated.
+	apiutil.Retry
+}
+
+// NewClient creates a client for the Gitea instance at address.
+func NewClient(address string, token string) *Client {
+	return &Client{
+		URL:        strings.TrimSuffix(address, "/"),
+		Token:      token,
+		HTTPClient: &http.Client{Timeout: 30 * time.Second},
+		Retry:      apiutil.DefaultRetry,
+	}
+}
+
+// send executes a JSON request against path and decodes the response
+// into jd. Failed attempts are retried with backoff. Unexpected
+// statuses are returned as *Error.
+func (c *Client) send(method string, path string, body interface{}, st int, jd interface{}) error {
+	req, err := apiutil.NewRequest(method, c.URL+APIPath+path, body, st, jd)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Authorization", "token "+c.Token)
+
+	_, err = c.Do(method, req.URL, func() (http.Header, bool, error) {
+		return req.Send(c.HTTPClient)
+	})
+	return err
+}
+
+func (c *Client) get(path string, jd interface{}) error {
+	return c.send("GET", path, nil, 200, jd)
+}
+
+func (c *Client) post(path string, body interface{}, jd interface{}) error {
+	return c.send("POST", path, body, 201, jd)
+}
+
+func (c *Client) patch(path string, body interface{}, jd interface{}) error {
+	return c.send("PATCH", path, body, 200, jd)
+}
@@ -0,0 +1,69 @@
+package gitea
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestErrors(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/api/v1/repos/foo/bar":
+			if r.Header.Get("Authorization") != "token secret" {
+				t.Errorf("Expected token, got %q", r.Header.Get("Authorization"))
+			}
+			w.Write([]byte(`{"id": 1, "name": "bar", "full_name": "foo/bar", "owner": {"login": "foo"}}`))
+		case "/api/v1/orgs/foo/repos":
+			http.Error(w, "exists", http.StatusConflict)
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer server.Close()
+
+	c := NewClient(server.URL+"/", "secret")
+	repo, err := c.GetRepository("foo", "bar")
+	if err != nil {
+		t.Fatal(err)
+	} else if repo.FullName != "foo/bar" || repo.Owner.Username != "foo" {
+		t.Errorf("Expected foo/bar, got %+v", repo)
+	}
+
+	_, err = c.GetRepository("foo", "missing")
+	if !IsNotFound(err) || IsAlreadyExists(err) {
+		t.Errorf("Expected not found, got %v", err)
+	}
+	_, err = c.CreateRepository("foo", CreateRepository{Name: "bar"})
+	if !IsAlreadyExists(err) || IsNotFound(err) {
+		t.Errorf("Expected already exists, got %v", err)
+	}
+}
+
+func TestRetries(t *testing.T) {
+	attempts := map[string]int{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		attempts[r.Method]++
+		if attempts[r.Method] < 3 {
+			http.Error(w, "unavailable", http.StatusBadGateway)
+			return
+		}
+		w.WriteHeader(map[string]int{"GET": 200, "POST": 201}[r.Method])
+		w.Write([]byte(`{"id": 1}`))
+	}))
+	defer server.Close()
+
+	c := NewClient(server.URL, "secret")
+	c.RetryWait, c.MaxRetryWait = time.Millisecond, time.Millisecond
+
+	_, err := c.GetRepository("foo", "bar")
+	if err != nil || attempts["GET"] != 3 {
+		t.Errorf("Expected GET to succeed on 3rd attempt, got %v after %v", err, attempts["GET"])
+	}
+	// the repository could have been created already
+	_, err = c.CreateRepository("", CreateRepository{Name: "bar"})
+	if err == nil || attempts["POST"] != 1 {
+		t.Errorf("Expected POST to fail without retries, got %v after %v", err, attempts["POST"])
+	}
+}
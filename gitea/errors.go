@@ -0,0 +1,19 @@
+package gitea
+
+import (
+	"github.com/ayufan/gitlab-mirror-post-fetch/apiutil"
+)
+
+// Error describes a failed Gitea API request.
+type Error = apiutil.Error
+
+// IsNotFound returns true if the error is caused by a missing resource.
+func IsNotFound(err error) bool {
+	return apiutil.HasStatus(err, 404)
+}
+
+// IsAlreadyExists returns true if the error is caused by creating
+// a repository that already exists.
+func IsAlreadyExists(err error) bool {
+	return apiutil.HasStatus(err, 409)
+}
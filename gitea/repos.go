@@ -0,0 +1,61 @@
+package gitea
+
+import (
+	"net/url"
+)
+
+// CurrentUser returns the owner of the token.
+func (c *Client) CurrentUser() (*User, error) {
+	var user User
+	err := c.get("/user", &user)
+	if err != nil {
+		return nil, err
+	}
+	return &user, nil
+}
+
+// GetOrganization returns the organization of the given name.
+func (c *Client) GetOrganization(name string) (*Organization, error) {
+	var org Organization
+	err := c.get("/orgs/"+url.PathEscape(name), &org)
+	if err != nil {
+		return nil, err
+	}
+	return &org, nil
+}
+
+// GetRepository returns the repository of owner, a user or an organization.
+func (c *Client) GetRepository(owner string, name string) (*Repository, error) {
+	var repo Repository
+	err := c.get("/repos/"+url.PathEscape(owner)+"/"+url.PathEscape(name), &repo)
+	if err != nil {
+		return nil, err
+	}
+	return &repo, nil
+}
+
+// CreateRepository creates the repository in the organization. If org
+// is empty, it's created for the owner of the token.
+func (c *Client) CreateRepository(org string, repo CreateRepository) (*Repository, error) {
+	path := "/user/repos"
+	if org != "" {
+		path = "/orgs/" + url.PathEscape(org) + "/repos"
+	}
+
+	var created Repository
+	err := c.post(path, repo, &created)
+	if err != nil {
+		return nil, err
+	}
+	return &created, nil
+}
+
+// EditRepository updates settings of the repository.
+func (c *Client) EditRepository(owner string, name string, edit EditRepository) (*Repository, error) {
+	var repo Repository
+	err := c.patch("/repos/"+url.PathEscape(owner)+"/"+url.PathEscape(name), edit, &repo)
+	if err != nil {
+		return nil, err
+	}
+	return &repo, nil
+}
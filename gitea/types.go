@@ -0,0 +1,40 @@
+package gitea
+
+type User struct {
+	Id       int    `json:"id"`
+	Username string `json:"login"`
+}
+
+type Organization struct {
+	Id       int    `json:"id"`
+	Username string `json:"username"`
+	FullName string `json:"full_name"`
+}
+
+type Repository struct {
+	Id            int    `json:"id"`
+	Name          string `json:"name"`
+	FullName      string `json:"full_name"`
+	Description   string `json:"description"`
+	Private       bool   `json:"private"`
+	DefaultBranch string `json:"default_branch"`
+	SshURL        string `json:"ssh_url"`
+	CloneURL      string `json:"clone_url"`
+	Owner         User   `json:"owner"`
+}
+
+type CreateRepository struct {
+	Name          string `json:"name"`
+	Description   string `json:"description,omitempty"`
+	Private       bool   `json:"private"`
+	DefaultBranch string `json:"default_branch,omitempty"`
+}
+
+// EditRepository changes only the fields that are set.
+type EditRepository struct {
+	Description     *string `json:"description,omitempty"`
+	HasIssues       *bool   `json:"has_issues,omitempty"`
+	HasWiki         *bool   `json:"has_wiki,omitempty"`
+	HasPullRequests *bool   `json:"has_pull_requests,omitempty"`
+	DefaultBranch   string  `json:"default_branch,omitempty"`
+}
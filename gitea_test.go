@@ -0,0 +1,121 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/ayufan/gitlab-mirror-post-fetch/gitea"
+)
+
+// fakeGitea serves repositories of the Mirrors organization
+// by name and creates new ones.
+func fakeGitea(t *testing.T, repos map[string]*gitea.Repository) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case r.Method == "GET" && strings.HasPrefix(r.URL.Path, "/api/v1/repos/Mirrors/"):
+			repo, ok := repos[strings.TrimPrefix(r.URL.Path, "/api/v1/repos/Mirrors/")]
+			if !ok {
+				http.NotFound(w, r)
+				return
+			}
+			json.NewEncoder(w).Encode(repo)
+		case r.Method == "POST" && r.URL.Path == "/api/v1/orgs/Mirrors/repos":
+			var create gitea.CreateRepository
+			if err := json.NewDecoder(r.Body).Decode(&create); err != nil {
+				t.Errorf("Failed to decode request: %v", err)
+			}
+			if _, ok := repos[create.Name]; ok {
+				http.Error(w, "conflict", http.StatusConflict)
+				return
+			}
+			repo := &gitea.Repository{Id: len(repos) + 1, Name: create.Name, FullName: "Mirrors/" + create.Name,
+				Description: create.Description, Owner: gitea.User{Username: "Mirrors"}}
+			repos[create.Name] = repo
+			w.WriteHeader(http.StatusCreated)
+			json.NewEncoder(w).Encode(repo)
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+}
+
+func TestGiteaFindProject(t *testing.T) {
+	namespace := &mirrorNamespace{Path: "Mirrors"}
+
+	tests := []struct {
+		repos map[string]string
+		path  string // of the new repository
+		found bool
+		err   bool
+	}{
+		// free path
+		{map[string]string{}, "a-b-c", false, false},
+		{map[string]string{"a-b-c": markDescription("Mirror", "github.com/a/b-c")}, "a-b-c", true, false},
+		{map[string]string{"a-b-c": markDescription("Mirror", "GitHub.com/A/B-C")}, "a-b-c", true, false},
+		// a-b/c came first
+		{map[string]string{"a-b-c": markDescription("Mirror", "github.com/a-b/c")}, "a-b-c-b37f4b", false, false},
+		{map[string]string{
+			"a-b-c":        markDescription("Mirror", "github.com/a-b/c"),
+			"a-b-c-b37f4b": markDescription("Mirror", "github.com/a/b-c"),
+		}, "a-b-c-b37f4b", true, false},
+		// repositories without upstream are never taken over
+		{map[string]string{"a-b-c": "Mirror of https://github.com/a/b-c.git"}, "a-b-c-b37f4b", false, false},
+		{map[string]string{"a-b-c": "", "a-b-c-b37f4b": "unrelated"}, "", false, true},
+	}
+
+	for _, test := range tests {
+		repos := map[string]*gitea.Repository{}
+		for name, description := range test.repos {
+			repos[name] = &gitea.Repository{Name: name, FullName: "Mirrors/" + name, Description: description}
+		}
+		server := fakeGitea(t, repos)
+
+		p := &giteaProvider{client: gitea.NewClient(server.URL, "token")}
+		target := testTarget(t, "https://github.com/a/b-c.git")
+		project, err := p.FindProject(namespace, target)
+		server.Close()
+
+		if (err != nil) != test.err || (project != nil) != test.found {
+			t.Errorf("With %v, expected found %v/error %v, got %+v/%v", test.repos, test.found, test.err, project, err)
+		} else if !test.err && !test.found && target.Path != test.path {
+			t.Errorf("With %v, expected path %v, got %v", test.repos, test.path, target.Path)
+		}
+	}
+}
+
+func TestGiteaCreateProject(t *testing.T) {
+	repos := map[string]*gitea.Repository{
+		"a-b-c": {Name: "a-b-c", FullName: "Mirrors/a-b-c", Description: markDescription("", "github.com/a-b/c")},
+	}
+	server := fakeGitea(t, repos)
+	defer server.Close()
+
+	namespace := &mirrorNamespace{Path: "Mirrors"}
+	p := &giteaProvider{client: gitea.NewClient(server.URL, "token")}
+	target := testTarget(t, "https://github.com/a/b-c.git")
+	target.Description = "Mirror of https://github.com/a/b-c.git"
+
+	project, err := p.FindProject(namespace, target)
+	if err != nil || project != nil {
+		t.Fatalf("Expected no project, got %+v/%v", project, err)
+	}
+	project, err = p.CreateProject(namespace, target)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if project.Path != "Mirrors/a-b-c-b37f4b" {
+		t.Errorf("Expected Mirrors/a-b-c-b37f4b, got %v", project.Path)
+	}
+	if upstream := descriptionUpstream(repos["a-b-c-b37f4b"].Description); upstream != "github.com/a/b-c" {
+		t.Errorf("Expected upstream github.com/a/b-c to be recorded, got %q", upstream)
+	}
+
+	// the next run finds it again
+	project, err = p.FindProject(namespace, testTarget(t, "https://github.com/a/b-c.git"))
+	if err != nil || project == nil || project.Path != "Mirrors/a-b-c-b37f4b" {
+		t.Errorf("Expected Mirrors/a-b-c-b37f4b, got %+v/%v", project, err)
+	}
+}
@@ -0,0 +1,135 @@
+// Package github is a small client for the parts of the GitHub API
+// needed to archive releases and issues of mirrored repositories.
+package github
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/ayufan/gitlab-mirror-post-fetch/apiutil"
+	"github.com/dustin/httputil"
+)
+
+const DefaultURL = "https://api.github.com"
+
+// Client talks to the GitHub API. Token is optional for public
+// repositories, but raises the rate limit.
+type Client struct {
+	// URL is the API address, e.g. https://github.example.com/api/v3
+	URL string
+
+	Token string
+
+	// HTTPClient is used for all requests. Its Timeout applies
+	// to every attempt separately.
+	HTTPClient *http.Client
+
+	// Retry controls how failed requests are repeated.
+	apiutil.Retry
+
+	rateLimit apiutil.RateLimit
+}
+
+// NewClient creates a client for the GitHub API at address.
+func NewClient(address string, token string) *Client {
+	return &Client{
+		URL:        strings.TrimSuffix(address, "/"),
+		Token:      token,
+		HTTPClient: &http.Client{Timeout: 30 * time.Second},
+		Retry:      apiutil.DefaultRetry,
+	}
+}
+
+// do gets url and returns the response of the first successful
+// attempt. The caller has to close its body.
+func (c *Client) do(http_client *http.Client, url string, accept string) (*http.Response, error) {
+	var res *http.Response
+	_, err := c.Do("GET", url, func() (http.Header, bool, error) {
+		c.rateLimit.Wait(c.MaxRetryWait)
+		var retry bool
+		var err error
+		res, retry, err = c.doOnce(http_client, url, accept)
+		if err != nil {
+			return nil, retry, err
+		}
+		return res.Header, false, nil
+	})
+	return res, err
+}
+
+// doOnce makes a single attempt at the request. It returns whether
+// a failed attempt is worth repeating.
+func (c *Client) doOnce(http_client *http.Client, url string, accept string) (*http.Response, bool, error) {
+	req, err := http.NewRequest("GET", url, nil)
+	if err != nil {
+		return nil, false, err
+	}
+	req.Header.Set("Accept", accept)
+	if c.Token != "" {
+		req.Header.Set("Authorization", "token "+c.Token)
+	}
+
+	res, err := http_client.Do(req)
+	if err != nil {
+		return nil, apiutil.IsRetryableError("GET", err), &Error{URL: url, Err: err}
+	}
+	c.rateLimit.Track(res.Header)
+
+	if res.StatusCode != 200 {
+		defer res.Body.Close()
+		return nil, isRetryable(res), &Error{URL: url, Err: httputil.HTTPError(res)}
+	}
+	return res, false, nil
+}
+
+// isRetryable returns true if the request failed temporarily. GitHub
+// answers 403 rather than 429 once the rate limit is used up, or with
+// Retry-After if requests were made too fast.
+func isRetryable(res *http.Response) bool {
+	if res.StatusCode == 403 {
+		return apiutil.ServerRetryWait(res.Header, time.Now()) > 0
+	}
+	return apiutil.IsRetryableStatus("GET", res.StatusCode)
+}
+
+// getPage fetches a single page into jd and returns the next page URL,
+// or an empty string after the last page.
+func (c *Client) getPage(url string, jd interface{}) (string, error) {
+	res, err := c.do(c.HTTPClient, url, "application/vnd.github.v3+json")
+	if err != nil {
+		return "", err
+	}
+	defer res.Body.Close()
+
+	err = json.NewDecoder(res.Body).Decode(jd)
+	if err != nil {
+		return "", &Error{URL: url, Err: fmt.Errorf("error decoding json payload: %v", err)}
+	}
+	return apiutil.ParseLink(res.Header.Get("Link"))["next"], nil
+}
+
+// download reads at most limit bytes of the file. Downloads
+// are not limited by the timeout of HTTPClient.
+func (c *Client) download(url string, limit int64) ([]byte, error) {
+	http_client := *c.HTTPClient
+	http_client.Timeout = 0
+	res, err := c.do(&http_client, url, "application/octet-stream")
+	if err != nil {
+		return nil, err
+	}
+	defer res.Body.Close()
+
+	data, err := ioutil.ReadAll(io.LimitReader(res.Body, limit+1))
+	if err != nil {
+		return nil, &Error{URL: url, Err: err}
+	}
+	if int64(len(data)) > limit {
+		return nil, &Error{URL: url, Err: fmt.Errorf("file larger than %d bytes", limit)}
+	}
+	return data, nil
+}
@@ -0,0 +1,26 @@
+package github
+
+import (
+	"fmt"
+
+	"github.com/dustin/httputil"
+)
+
+// Error describes a failed GitHub API request.
+type Error struct {
+	URL string
+	Err error
+}
+
+// Error satisfies the "error" interface.
+func (e *Error) Error() string {
+	return fmt.Sprintf("couldn't execute GET against %v: %v", e.URL, e.Err)
+}
+
+// IsNotFound returns true if the error is caused by a missing resource.
+func IsNotFound(err error) bool {
+	if e, ok := err.(*Error); ok {
+		err = e.Err
+	}
+	return httputil.IsHTTPStatus(err, 404)
+}
@@ -0,0 +1,118 @@
+package github
+
+import (
+	"net/url"
+	"path"
+	"strconv"
+	"time"
+)
+
+func (c *Client) repoURL(owner string, repo string) string {
+	return c.URL + "/repos/" + url.PathEscape(owner) + "/" + url.PathEscape(repo)
+}
+
+func listQuery(since time.Time) string {
+	query := url.Values{}
+	query.Set("per_page", "100")
+	if !since.IsZero() {
+		query.Set("since", since.UTC().Format(time.RFC3339))
+	}
+	return "?" + query.Encode()
+}
+
+// Releases lists releases of the repository, newest first.
+func (c *Client) Releases(owner string, repo string) ([]*Release, error) {
+	var releases []*Release
+	next := c.repoURL(owner, repo) + "/releases" + listQuery(time.Time{})
+	for next != "" {
+		var page []*Release
+		var err error
+		next, err = c.getPage(next, &page)
+		if err != nil {
+			return nil, err
+		}
+		releases = append(releases, page...)
+	}
+	return releases, nil
+}
+
+// Issues lists issues and pull requests updated since the given
+// time, least recently updated first.
+func (c *Client) Issues(owner string, repo string, since time.Time) ([]*Issue, error) {
+	query := listQuery(since) + "&state=all&sort=updated&direction=asc"
+	var issues []*Issue
+	next := c.repoURL(owner, repo) + "/issues" + query
+	for next != "" {
+		var page []*Issue
+		var err error
+		next, err = c.getPage(next, &page)
+		if err != nil {
+			return nil, err
+		}
+		issues = append(issues, page...)
+	}
+	return issues, nil
+}
+
+// IssueComments lists comments of all issues and pull requests
+// updated since the given time, least recently updated first.
+func (c *Client) IssueComments(owner string, repo string, since time.Time) ([]*Comment, error) {
+	query := listQuery(since) + "&sort=updated&direction=asc"
+	var comments []*Comment
+	next := c.repoURL(owner, repo) + "/issues/comments" + query
+	for next != "" {
+		var page []*Comment
+		var err error
+		next, err = c.getPage(next, &page)
+		if err != nil {
+			return nil, err
+		}
+		comments = append(comments, page...)
+	}
+	return comments, nil
+}
+
+// Issue returns the issue or pull request of the given number.
+func (c *Client) Issue(owner string, repo string, number int) (*Issue, error) {
+	var issue Issue
+	_, err := c.getPage(c.repoURL(owner, repo)+"/issues/"+strconv.Itoa(number), &issue)
+	if err != nil {
+		return nil, err
+	}
+	return &issue, nil
+}
+
+// PullRequestComments lists review comments of all pull requests
+// updated since the given time, least recently updated first.
+func (c *Client) PullRequestComments(owner string, repo string, since time.Time) ([]*Comment, error) {
+	query := listQuery(since) + "&sort=updated&direction=asc"
+	var comments []*Comment
+	next := c.repoURL(owner, repo) + "/pulls/comments" + query
+	for next != "" {
+		var page []*Comment
+		var err error
+		next, err = c.getPage(next, &page)
+		if err != nil {
+			return nil, err
+		}
+		comments = append(comments, page...)
+	}
+	return comments, nil
+}
+
+// IssueNumber returns the number of the issue or pull request
+// the comment belongs to.
+func (c *Comment) IssueNumber() int {
+	issue_url := c.IssueURL
+	if issue_url == "" {
+		issue_url = c.PullRequestURL
+	}
+	number, _ := strconv.Atoi(path.Base(issue_url))
+	return number
+}
+
+// DownloadAsset reads the file of the release asset, which
+// can't be larger than limit.
+func (c *Client) DownloadAsset(asset Asset, limit int64) ([]byte, error) {
+	return c.download(asset.URL, limit)
+}
@@ -0,0 +1,129 @@
+package github
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestPullRequestComments(t *testing.T) {
+	var server *httptest.Server
+	server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/repos/foo/bar/pulls/comments":
+			if r.URL.Query().Get("since") != "2016-01-02T15:04:05Z" || r.URL.Query().Get("sort") != "updated" {
+				t.Errorf("Unexpected query %v", r.URL.RawQuery)
+			}
+			w.Header().Set("Link", fmt.Sprintf(`<%v/page2>; rel="next"`, server.URL))
+			fmt.Fprint(w, `[{"id": 1, "path": "main.go", "pull_request_url": "https://api.github.com/repos/foo/bar/pulls/12"}]`)
+		case "/page2":
+			fmt.Fprint(w, `[{"id": 2, "path": "main.go", "pull_request_url": "https://api.github.com/repos/foo/bar/pulls/13"}]`)
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer server.Close()
+
+	c := NewClient(server.URL, "")
+	comments, err := c.PullRequestComments("foo", "bar", time.Date(2016, 1, 2, 15, 4, 5, 0, time.UTC))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(comments) != 2 || comments[0].Id != 1 || comments[1].Id != 2 {
+		t.Fatalf("Expected comments 1 and 2, got %+v", comments)
+	}
+	if comments[0].IssueNumber() != 12 || comments[1].IssueNumber() != 13 {
+		t.Errorf("Expected issues 12 and 13, got %v and %v", comments[0].IssueNumber(), comments[1].IssueNumber())
+	}
+}
+
+func TestIssueNumber(t *testing.T) {
+	tests := []struct {
+		comment Comment
+		exp     int
+	}{
+		{Comment{IssueURL: "https://api.github.com/repos/foo/bar/issues/7"}, 7},
+		{Comment{PullRequestURL: "https://api.github.com/repos/foo/bar/pulls/12"}, 12},
+		{Comment{}, 0},
+	}
+
+	for _, test := range tests {
+		got := test.comment.IssueNumber()
+		if got != test.exp {
+			t.Errorf("On %+v, expected %v, got %v", test.comment, test.exp, got)
+		}
+	}
+}
+
+func TestIssue(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/repos/foo/bar/issues/7" {
+			http.NotFound(w, r)
+			return
+		}
+		fmt.Fprint(w, `{"number": 7, "title": "Crash", "state": "open"}`)
+	}))
+	defer server.Close()
+
+	c := NewClient(server.URL, "")
+	issue, err := c.Issue("foo", "bar", 7)
+	if err != nil {
+		t.Fatal(err)
+	} else if issue.Number != 7 || issue.Title != "Crash" {
+		t.Errorf("Expected issue 7, got %+v", issue)
+	}
+
+	_, err = c.Issue("foo", "bar", 8)
+	if !IsNotFound(err) {
+		t.Errorf("Expected not found, got %v", err)
+	}
+}
+
+func TestRetries(t *testing.T) {
+	attempts := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		attempts++
+		switch attempts {
+		case 1:
+			http.Error(w, "unavailable", http.StatusBadGateway)
+		case 2:
+			// secondary rate limit
+			w.Header().Set("Retry-After", "60")
+			w.Header().Set("X-RateLimit-Remaining", "0")
+			w.Header().Set("X-RateLimit-Reset", fmt.Sprint(time.Now().Add(time.Hour).Unix()))
+			http.Error(w, "rate limited", http.StatusForbidden)
+		default:
+			fmt.Fprint(w, `{"number": 7}`)
+		}
+	}))
+	defer server.Close()
+
+	c := NewClient(server.URL, "")
+	c.RetryWait, c.MaxRetryWait = time.Millisecond, time.Millisecond
+	issue, err := c.Issue("foo", "bar", 7)
+	if err != nil || issue.Number != 7 {
+		t.Fatalf("Expected issue 7, got %+v/%v", issue, err)
+	}
+	if attempts != 3 {
+		t.Errorf("Expected 3 attempts, got %v", attempts)
+	}
+}
+
+func TestForbidden(t *testing.T) {
+	attempts := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		attempts++
+		w.Header().Set("X-RateLimit-Remaining", "4999")
+		http.Error(w, "forbidden", http.StatusForbidden)
+	}))
+	defer server.Close()
+
+	c := NewClient(server.URL, "")
+	c.RetryWait, c.MaxRetryWait = time.Millisecond, time.Millisecond
+	_, err := c.Issue("foo", "bar", 7)
+	if err == nil || attempts != 1 {
+		t.Errorf("Expected failure without retries, got %v after %v attempts", err, attempts)
+	}
+}
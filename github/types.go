@@ -0,0 +1,67 @@
+package github
+
+import (
+	"time"
+)
+
+type User struct {
+	Login string `json:"login"`
+}
+
+type Label struct {
+	Name string `json:"name"`
+}
+
+type Asset struct {
+	Id                 int    `json:"id"`
+	Name               string `json:"name"`
+	Size               int64  `json:"size"`
+	URL                string `json:"url"`
+	BrowserDownloadURL string `json:"browser_download_url"`
+}
+
+type Release struct {
+	Id              int       `json:"id"`
+	TagName         string    `json:"tag_name"`
+	TargetCommitish string    `json:"target_commitish"`
+	Name            string    `json:"name"`
+	Body            string    `json:"body"`
+	Draft           bool      `json:"draft"`
+	Prerelease      bool      `json:"prerelease"`
+	HtmlURL         string    `json:"html_url"`
+	CreatedAt       time.Time `json:"created_at"`
+	PublishedAt     time.Time `json:"published_at"`
+	Assets          []Asset   `json:"assets"`
+}
+
+// Issue is an issue or, if PullRequest is set, a pull request.
+type Issue struct {
+	Id          int       `json:"id"`
+	Number      int       `json:"number"`
+	Title       string    `json:"title"`
+	Body        string    `json:"body"`
+	State       string    `json:"state"`
+	User        User      `json:"user"`
+	Labels      []Label   `json:"labels"`
+	HtmlURL     string    `json:"html_url"`
+	CreatedAt   time.Time `json:"created_at"`
+	UpdatedAt   time.Time `json:"updated_at"`
+	PullRequest *struct {
+		HtmlURL string `json:"html_url"`
+	} `json:"pull_request"`
+}
+
+// Comment is a comment on an issue or a pull request, or a review
+// comment on a file of a pull request. IssueURL or PullRequestURL
+// ends with the number of the issue.
+type Comment struct {
+	Id             int64     `json:"id"`
+	Body           string    `json:"body"`
+	User           User      `json:"user"`
+	HtmlURL        string    `json:"html_url"`
+	IssueURL       string    `json:"issue_url"`
+	PullRequestURL string    `json:"pull_request_url"`
+	Path           string    `json:"path"`
+	CreatedAt      time.Time `json:"created_at"`
+	UpdatedAt      time.Time `json:"updated_at"`
+}
@@ -0,0 +1,368 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"net/url"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/ayufan/gitlab-mirror-post-fetch/github"
+	"github.com/ayufan/gitlab-mirror-post-fetch/gitlab"
+)
+
+// maxAssetSize matches the default attachment size limit of GitLab.
+// Larger assets are linked to GitHub instead.
+const maxAssetSize = 100 << 20
+
+// githubState tracks what was already copied from GitHub, so every
+// run picks up only what changed since the previous one.
+type githubState struct {
+	ReleasesSince       time.Time `json:"releases_since"`
+	IssuesSince         time.Time `json:"issues_since"`
+	CommentsSince       time.Time `json:"comments_since"`
+	ReviewCommentsSince time.Time `json:"review_comments_since"`
+
+	// Issues maps GitHub issue numbers to GitLab issue iids,
+	// Comments and ReviewComments map GitHub comment ids to GitLab
+	// note ids.
+	Issues         map[int]int   `json:"issues"`
+	Comments       map[int64]int `json:"comments"`
+	ReviewComments map[int64]int `json:"review_comments"`
+}
+
+func githubStatePath() string {
+	return filepath.Join(stateDir(), *gitlab_remote+".github.json")
+}
+
+func loadGitHubState() (*githubState, error) {
+	state := &githubState{}
+	data, err := ioutil.ReadFile(githubStatePath())
+	if err == nil {
+		err = json.Unmarshal(data, state)
+	} else if os.IsNotExist(err) {
+		err = nil
+	}
+	if state.Issues == nil {
+		state.Issues = make(map[int]int)
+	}
+	if state.Comments == nil {
+		state.Comments = make(map[int64]int)
+	}
+	if state.ReviewComments == nil {
+		state.ReviewComments = make(map[int64]int)
+	}
+	return state, err
+}
+
+func (s *githubState) save() {
+	data, err := json.MarshalIndent(s, "", "\t")
+	if err == nil {
+		err = os.MkdirAll(stateDir(), 0700)
+	}
+	if err == nil {
+		err = writeFileAtomic(githubStatePath(), data, 0600)
+	}
+	if err != nil {
+		log.Fatalf("Failed to save GitHub sync state: %v", err)
+	}
+}
+
+// githubRepository returns owner and name of the upstream repository,
+// if it's hosted on the GitHub the API belongs to.
+func githubRepository() (string, string, bool) {
+	repo_url, err := readOriginRemote()
+	if err != nil {
+		log.Fatalf("Failed to read %v remote: %v", *origin_remote, err)
+	}
+	api_url, err := url.Parse(*github_url)
+	if err != nil {
+		log.Fatalf("Invalid GitHub API URL %v: %v", *github_url, err)
+	}
+
+	host := api_url.Host
+	if host == "api.github.com" {
+		host = "github.com"
+	}
+	if repo_url.Hostname() != strings.Split(host, ":")[0] {
+		return "", "", false
+	}
+
+	full_name := strings.TrimSuffix(strings.TrimPrefix(repo_url.Path, "/"), ".git")
+	parts := strings.Split(full_name, "/")
+	if len(parts) != 2 {
+		return "", "", false
+	}
+	return parts[0], parts[1], true
+}
+
+var mentionRegexp = regexp.MustCompile(`(^|[^\w])@(\w)`)
+
+// quoteGitHub keeps @mentions of GitHub users from
+// notifying GitLab users of the same name.
+func quoteGitHub(text string) string {
+	return mentionRegexp.ReplaceAllString(text, "$1@\u200b$2")
+}
+
+func githubDate(t time.Time) string {
+	return t.UTC().Format("2006-01-02 15:04 MST")
+}
+
+func syncReleaseAssets(gh *github.Client, project_data *gitlab.Project, release *github.Release) []gitlab.ReleaseLink {
+	var links []gitlab.ReleaseLink
+	for _, asset := range release.Assets {
+		link := gitlab.ReleaseLink{Name: asset.Name, URL: asset.BrowserDownloadURL}
+		if asset.Size > maxAssetSize {
+			log.Printf("Linking asset %v of %v to GitHub, it's too large to upload...", asset.Name, release.TagName)
+			links = append(links, link)
+			continue
+		}
+
+		log.Printf("Uploading asset %v of %v...", asset.Name, release.TagName)
+		data, err := gh.DownloadAsset(asset, maxAssetSize)
+		if err != nil {
+			log.Fatalf("Failed to download asset %v: %v", asset.Name, err)
+		}
+		upload, err := client.UploadFile(project_data.Id, asset.Name, data)
+		if err != nil {
+			log.Fatalf("Failed to upload asset %v: %v", asset.Name, err)
+		}
+		link.URL = project_data.WebUrl + upload.URL
+		links = append(links, link)
+	}
+	return links
+}
+
+func syncReleases(gh *github.Client, project_data *gitlab.Project, owner, name string, state *githubState) {
+	log.Printf("Looking for new releases of %v/%v...", owner, name)
+	releases, err := gh.Releases(owner, name)
+	if err != nil {
+		log.Fatalf("Failed to list releases of %v/%v: %v", owner, name, err)
+	}
+	// releases are published later than they are created,
+	// so the publishing date is used to pick up new ones
+	sort.Slice(releases, func(i, j int) bool {
+		return releases[i].PublishedAt.Before(releases[j].PublishedAt)
+	})
+
+	for _, release := range releases {
+		if release.Draft || !release.PublishedAt.After(state.ReleasesSince) {
+			continue
+		}
+
+		_, err := client.GetRelease(project_data.Id, release.TagName)
+		if err == nil {
+			log.Printf("Release %v already exists...", release.TagName)
+		} else if gitlab.IsNotFound(err) {
+			log.Printf("Creating release %v...", release.TagName)
+			new_release := gitlab.CreateRelease{
+				TagName:     release.TagName,
+				Name:        release.Name,
+				Description: quoteGitHub(release.Body),
+				Ref:         release.TargetCommitish,
+				ReleasedAt:  release.PublishedAt.UTC().Format(time.RFC3339),
+				Assets:      &gitlab.ReleaseAssets{Links: syncReleaseAssets(gh, project_data, release)},
+			}
+			if new_release.Name == "" {
+				new_release.Name = release.TagName
+			}
+			_, err = client.CreateRelease(project_data.Id, new_release)
+		}
+		if err != nil {
+			log.Fatalf("Failed to create release %v: %v", release.TagName, err)
+		}
+
+		state.ReleasesSince = release.PublishedAt
+		state.save()
+	}
+}
+
+func issueDescription(issue *github.Issue, full_name string) string {
+	kind := "issue"
+	if issue.PullRequest != nil {
+		kind = "pull request"
+	}
+	return fmt.Sprintf("*Archived %v [%v#%d](%v), opened by %v on %v.*\n\n%v",
+		kind, full_name, issue.Number, issue.HtmlURL, issue.User.Login, githubDate(issue.CreatedAt), quoteGitHub(issue.Body))
+}
+
+func issueLabels(issue *github.Issue) string {
+	labels := []string{"github-issue"}
+	if issue.PullRequest != nil {
+		labels[0] = "github-pull-request"
+	}
+	for _, label := range issue.Labels {
+		labels = append(labels, strings.Replace(label.Name, ",", " ", -1))
+	}
+	return strings.Join(labels, ",")
+}
+
+// archiveIssue creates or updates the GitLab issue of the GitHub issue.
+func archiveIssue(project_data *gitlab.Project, issue *github.Issue, full_name string, state *githubState) error {
+	state_event := "reopen"
+	if issue.State == "closed" {
+		state_event = "close"
+	}
+	edit := gitlab.EditIssue{
+		Title:       issue.Title,
+		Description: issueDescription(issue, full_name),
+		Labels:      issueLabels(issue),
+		StateEvent:  state_event,
+	}
+
+	iid, ok := state.Issues[issue.Number]
+	if ok {
+		log.Printf("Updating #%d...", issue.Number)
+		_, err := client.EditIssue(project_data.Id, iid, edit)
+		return err
+	}
+
+	log.Printf("Archiving #%d...", issue.Number)
+	created, err := client.CreateIssue(project_data.Id, gitlab.CreateIssue{
+		Title:       edit.Title,
+		Description: edit.Description,
+		Labels:      edit.Labels,
+	})
+	if err != nil {
+		return err
+	}
+	state.Issues[issue.Number] = created.Iid
+	state.save()
+	if issue.State == "closed" {
+		_, err = client.EditIssue(project_data.Id, created.Iid, gitlab.EditIssue{StateEvent: "close"})
+	}
+	return err
+}
+
+// archivedIssue returns the iid of the GitLab issue of the GitHub
+// issue. Issues updated after they were listed are archived first.
+func archivedIssue(gh *github.Client, project_data *gitlab.Project, owner, name string, number int, state *githubState) (int, error) {
+	if iid, ok := state.Issues[number]; ok {
+		return iid, nil
+	}
+
+	issue, err := gh.Issue(owner, name, number)
+	if err != nil {
+		return 0, err
+	}
+	err = archiveIssue(project_data, issue, owner+"/"+name, state)
+	if err != nil {
+		return 0, err
+	}
+	return state.Issues[number], nil
+}
+
+// archiveComments copies comments to notes of the archived issues.
+// Since and notes are the cursor and the note ids of the kind of
+// comments.
+func archiveComments(gh *github.Client, project_data *gitlab.Project, owner, name string, comments []*github.Comment, since *time.Time, notes map[int64]int, state *githubState) {
+	for _, comment := range comments {
+		note_id, ok := notes[comment.Id]
+		if ok && !comment.UpdatedAt.After(*since) {
+			// since includes the last comment of the previous run
+			continue
+		}
+
+		iid, err := archivedIssue(gh, project_data, owner, name, comment.IssueNumber(), state)
+		if github.IsNotFound(err) {
+			log.Printf("Skipping comment %v of deleted #%d...", comment.HtmlURL, comment.IssueNumber())
+			*since = comment.UpdatedAt
+			state.save()
+			continue
+		} else if err != nil {
+			log.Fatalf("Failed to archive #%d: %v", comment.IssueNumber(), err)
+		}
+
+		kind := "Comment"
+		if comment.Path != "" {
+			kind = fmt.Sprintf("Review comment on `%v`", comment.Path)
+		}
+		body := fmt.Sprintf("*[%v](%v) by %v on %v:*\n\n%v",
+			kind, comment.HtmlURL, comment.User.Login, githubDate(comment.CreatedAt), quoteGitHub(comment.Body))
+		if ok {
+			_, err = client.EditIssueNote(project_data.Id, iid, note_id, body)
+		} else {
+			var note *gitlab.Note
+			note, err = client.CreateIssueNote(project_data.Id, iid, body)
+			if err == nil {
+				notes[comment.Id] = note.Id
+			}
+		}
+		if err != nil {
+			log.Fatalf("Failed to archive comment %v: %v", comment.HtmlURL, err)
+		}
+		*since = comment.UpdatedAt
+		state.save()
+	}
+}
+
+func syncIssues(gh *github.Client, project_data *gitlab.Project, owner, name string, state *githubState) {
+	log.Printf("Looking for updated issues of %v/%v...", owner, name)
+	issues, err := gh.Issues(owner, name, state.IssuesSince)
+	if err != nil {
+		log.Fatalf("Failed to list issues of %v/%v: %v", owner, name, err)
+	}
+
+	for _, issue := range issues {
+		_, ok := state.Issues[issue.Number]
+		if ok && !issue.UpdatedAt.After(state.IssuesSince) {
+			// since includes the last issue of the previous run
+			continue
+		}
+		err = archiveIssue(project_data, issue, owner+"/"+name, state)
+		if err != nil {
+			log.Fatalf("Failed to archive #%d: %v", issue.Number, err)
+		}
+		state.IssuesSince = issue.UpdatedAt
+		state.save()
+	}
+
+	log.Printf("Looking for updated comments of %v/%v...", owner, name)
+	comments, err := gh.IssueComments(owner, name, state.CommentsSince)
+	if err != nil {
+		log.Fatalf("Failed to list comments of %v/%v: %v", owner, name, err)
+	}
+	archiveComments(gh, project_data, owner, name, comments, &state.CommentsSince, state.Comments, state)
+
+	log.Printf("Looking for updated review comments of %v/%v...", owner, name)
+	comments, err = gh.PullRequestComments(owner, name, state.ReviewCommentsSince)
+	if err != nil {
+		log.Fatalf("Failed to list review comments of %v/%v: %v", owner, name, err)
+	}
+	archiveComments(gh, project_data, owner, name, comments, &state.ReviewCommentsSince, state.ReviewComments, state)
+}
+
+// doSyncGitHub copies releases and, optionally, issues and pull
+// requests of a GitHub repository to the project.
+func doSyncGitHub() {
+	owner, name, ok := githubRepository()
+	if !ok {
+		log.Printf("Upstream is not hosted on GitHub, skipping releases and issues.")
+		return
+	}
+
+	state, err := loadGitHubState()
+	if err != nil {
+		log.Fatalf("Failed to load GitHub sync state: %v", err)
+	}
+
+	gh := github.NewClient(*github_url, *github_token)
+	gh.HTTPClient.Timeout = *timeout
+	gh.MaxRetries = *retries
+	gh.RetryWait = *retry_wait
+	gh.MaxRetryWait = *max_retry_wait
+	project_data := doFindRemoteProject()
+
+	if *sync_releases {
+		syncReleases(gh, project_data, owner, name, state)
+	}
+	if *sync_issues {
+		syncIssues(gh, project_data, owner, name, state)
+	}
+}
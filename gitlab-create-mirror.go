@@ -1,77 +1,75 @@
 package main
 
 import (
-	"bytes"
-	"encoding/json"
 	"errors"
 	"flag"
 	"fmt"
-	"github.com/dustin/httputil"
+	"github.com/ayufan/gitlab-mirror-post-fetch/github"
+	"github.com/ayufan/gitlab-mirror-post-fetch/gitlab"
 	"io"
 	"io/ioutil"
 	"log"
-	"net/http"
 	"net/url"
 	"os"
 	"os/exec"
+	"regexp"
+	"strconv"
 	"strings"
 	"time"
 )
 
 var (
 	address          = flag.String("gitlab-url", getEnvOrDefault("GITLAB_URL", ""), "GitLab URL [GITLAB_URL]")
-	api_path         = flag.String("gitlab-api-path", "/api/v3", "GitLab API path")
+	api_path         = flag.String("gitlab-api-path", getEnvOrDefault("GITLAB_API_PATH", ""), "GitLab API path, detected when empty [GITLAB_API_PATH]")
 	group            = flag.String("gitlab-group", getEnvOrDefault("GITLAB_GROUP", ""), "GitLab Group [GITLAB_GROUP]")
 	trim_name        = flag.String("trim-name", getEnvOrDefault("TRIM_NAME", ""), "Trim prefix from project name [TRIM_NAME]")
+	namespace_mode   = flag.String("namespace-mode", getEnvOrDefault("NAMESPACE_MODE", "flat"), "Select flat to name projects owner-repo or nested to create owner subgroups [NAMESPACE_MODE]")
 	private_token    = flag.String("gitlab-private-token", getEnvOrDefault("GITLAB_PRIVATE_TOKEN", ""), "GitLab Mirror Private Token [GITLAB_PRIVATE_TOKEN]")
 	visibility_level = flag.String("gitlab-visibility-level", getEnvOrDefault("GITLAB_VISIBILITIY_LEVEL", "private"), "Select private, internal or public [GITLAB_VISIBILITIY_LEVEL]")
 	git              = flag.String("git", "/usr/bin/git", "path to git")
 	origin_remote    = flag.String("origin-remote", "origin", "Source remote name")
 	gitlab_remote    = flag.String("gitlab-remote", "gitlab", "Git remote name")
+	push_transport   = flag.String("push-transport", getEnvOrDefault("GITLAB_PUSH_TRANSPORT", "ssh"), "Select ssh or https to push with a token [GITLAB_PUSH_TRANSPORT]")
+	push_username    = flag.String("push-username", getEnvOrDefault("GITLAB_PUSH_USERNAME", "oauth2"), "Username used for pushes over https [GITLAB_PUSH_USERNAME]")
+	push_token       = flag.String("push-token", getEnvOrDefault("GITLAB_PUSH_TOKEN", ""), "Token used for pushes over https, the private token by default [GITLAB_PUSH_TOKEN]")
+	known_hosts      = flag.String("known-hosts", getEnvOrDefault("GITLAB_KNOWN_HOSTS", defaultKnownHosts()), "known_hosts file used for pushes over ssh [GITLAB_KNOWN_HOSTS]")
+	ssh_host_keys    = flag.String("ssh-host-keys", getEnvOrDefault("GITLAB_SSH_HOST_KEYS", ""), "Newline separated known_hosts lines trusted for pushes [GITLAB_SSH_HOST_KEYS]")
+	learn_host_keys  = flag.Bool("learn-host-keys", getEnvOrDefault("GITLAB_LEARN_HOST_KEYS", "true") == "true", "Trust host keys of unknown hosts on first use [GITLAB_LEARN_HOST_KEYS]")
+	deploy_keys      = flag.Bool("deploy-keys", getEnvOrDefault("GITLAB_DEPLOY_KEYS", "false") == "true", "Push with a deploy key generated for every mirror [GITLAB_DEPLOY_KEYS]")
+	provider_name    = flag.String("provider", getEnvOrDefault("MIRROR_PROVIDER", "gitlab"), "Select gitlab or gitea as the service mirrors are pushed to [MIRROR_PROVIDER]")
+	target           = flag.String("target", getEnvOrDefault("GITLAB_MIRROR_TARGET", ""), "Mirror only to the named target of the configuration file [GITLAB_MIRROR_TARGET]")
+	config_path      = flag.String("config", getEnvOrDefault("GITLAB_MIRROR_CONFIG", ""), "Path to JSON configuration file [GITLAB_MIRROR_CONFIG]")
+	sync_metadata    = flag.Bool("sync-metadata", getEnvOrDefault("SYNC_METADATA", "true") == "true", "Update description, topics and default branch from the webhook payload [SYNC_METADATA]")
+	sync_head        = flag.Bool("sync-head", getEnvOrDefault("SYNC_HEAD", "true") == "true", "Follow the upstream default branch with HEAD and the project default branch [SYNC_HEAD]")
+	dry_run          = flag.Bool("dry-run", false, "Print where the repository would be mirrored and exit")
+	retries          = flag.Int("gitlab-retries", getEnvIntOrDefault("GITLAB_RETRIES", 3), "Number of retries of failed GitLab, Gitea or GitHub requests [GITLAB_RETRIES]")
+	retry_wait       = flag.Duration("gitlab-retry-wait", getEnvDurationOrDefault("GITLAB_RETRY_WAIT", time.Second), "Initial delay between retries, doubled on each retry [GITLAB_RETRY_WAIT]")
+	max_retry_wait   = flag.Duration("gitlab-max-retry-wait", getEnvDurationOrDefault("GITLAB_MAX_RETRY_WAIT", 30*time.Second), "Maximum delay between retries [GITLAB_MAX_RETRY_WAIT]")
+	timeout          = flag.Duration("gitlab-timeout", getEnvDurationOrDefault("GITLAB_TIMEOUT", 30*time.Second), "Timeout of a single GitLab request [GITLAB_TIMEOUT]")
+	push_retries     = flag.Int("push-retries", getEnvIntOrDefault("PUSH_RETRIES", 3), "Number of retries of pushes failed for network reasons [PUSH_RETRIES]")
+	push_retry_wait  = flag.Duration("push-retry-wait", getEnvDurationOrDefault("PUSH_RETRY_WAIT", 5*time.Second), "Initial delay between push retries, doubled on each retry [PUSH_RETRY_WAIT]")
+	include_refs     = flag.String("include-refs", getEnvOrDefault("MIRROR_INCLUDE_REFS", ""), "Comma separated patterns of mirrored refs, eg. refs/heads/*,refs/tags/* [MIRROR_INCLUDE_REFS]")
+	exclude_refs     = flag.String("exclude-refs", getEnvOrDefault("MIRROR_EXCLUDE_REFS", ""), "Comma separated patterns of refs that are not mirrored [MIRROR_EXCLUDE_REFS]")
+	safe_mode        = flag.String("safe-mode", getEnvOrDefault("MIRROR_SAFE_MODE", "off"), "Select archive to keep or refuse to push force-pushed and deleted protected refs [MIRROR_SAFE_MODE]")
+	protected_refs   = flag.String("protected-refs", getEnvOrDefault("MIRROR_PROTECTED_REFS", "refs/heads/*,refs/tags/*"), "Comma separated patterns of refs protected by safe mode [MIRROR_PROTECTED_REFS]")
+	lfs              = flag.String("lfs", getEnvOrDefault("MIRROR_LFS", "auto"), "Select on, off or auto to mirror Git LFS objects of repositories using it [MIRROR_LFS]")
+	mirror_wiki      = flag.Bool("wiki", getEnvOrDefault("MIRROR_WIKI", "false") == "true", "Mirror the upstream wiki to the project wiki [MIRROR_WIKI]")
+	sync_releases    = flag.Bool("sync-releases", getEnvOrDefault("SYNC_RELEASES", "false") == "true", "Copy GitHub releases and their assets to the project [SYNC_RELEASES]")
+	sync_issues      = flag.Bool("sync-issues", getEnvOrDefault("SYNC_ISSUES", "false") == "true", "Archive GitHub issues and pull requests as issues of the project [SYNC_ISSUES]")
+	github_url       = flag.String("github-api-url", getEnvOrDefault("GITHUB_API_URL", github.DefaultURL), "GitHub API URL [GITHUB_API_URL]")
+	github_token     = flag.String("github-token", getEnvOrDefault("GITHUB_TOKEN", ""), "GitHub token used to read releases and issues [GITHUB_TOKEN]")
+	verify_push      = flag.Bool("verify-push", getEnvOrDefault("VERIFY_PUSH", "true") == "true", "Compare remote refs with local ones after push [VERIFY_PUSH]")
+
+	client          *gitlab.Client
+	config          *Config
+	mirror_provider provider
+	ref_filter      *refFilter
+
+	// remote_project is the project the GitLab remote points to,
+	// see doFindRemoteProject
+	remote_project *gitlab.Project
 )
 
-const (
-	groups_url   = "/groups"
-	projects_url = "/projects"
-)
-
-type Group struct {
-	Id      int    `json:"id,omitempty"`
-	Name    string `json:"name,omitempty"`
-	Path    string `json:"path,omitempty"`
-	OwnerId int    `json:"owner_id,omitempty"`
-}
-
-type CreateProject struct {
-	Name                 string `json:"name,omitempty"`
-	Description          string `json:"description,omitempty"`
-	Path                 string `json:"path,omitempty"`
-	IssuesEnabled        bool   `json:"issues_enabled"`
-	MergeRequestsEnabled bool   `json:"merge_requests_enabled"`
-	WikiEnabled          bool   `json:"wiki_enabled"`
-	SnippetsEnabled      bool   `json:"snippets_enabled"`
-	NamespaceId          int    `json:"namespace_id,omitempty"`
-	VisibilityLevel      int    `json:"visibility_level"`
-}
-
-type Namespace struct {
-	Id          int    `json:"id,omitempty"`
-	Name        string `json:"name,omitempty"`
-	Description string `json:"description,omitempty"`
-	Path        string `json:"path,omitempty"`
-}
-
-type Project struct {
-	Id          int        `json:"id,omitempty"`
-	Name        string     `json:"name,omitempty"`
-	Description string     `json:"description,omitempty"`
-	Public      bool       `json:"public,omitempty"`
-	Path        string     `json:"path,omitempty"`
-	SshRepoUrl  string     `json:"ssh_url_to_repo"`
-	HttpRepoUrl string     `json:"http_url_to_repo"`
-	Namespace   *Namespace `json:"namespace"`
-}
-
 func getEnvOrDefault(env string, defaultValue string) string {
 	value := os.Getenv(env)
 	if value == "" {
@@ -80,6 +78,22 @@ func getEnvOrDefault(env string, defaultValue string) string {
 	return value
 }
 
+func getEnvIntOrDefault(env string, defaultValue int) int {
+	value, err := strconv.Atoi(os.Getenv(env))
+	if err != nil {
+		return defaultValue
+	}
+	return value
+}
+
+func getEnvDurationOrDefault(env string, defaultValue time.Duration) time.Duration {
+	value, err := time.ParseDuration(os.Getenv(env))
+	if err != nil {
+		return defaultValue
+	}
+	return value
+}
+
 func readPayload(r io.Reader) ([]byte, error) {
 	maxPayloadSize := int64(1<<63 - 1)
 	maxPayloadSize = int64(10 << 20) // 10 MB is a lot of text.
@@ -94,159 +108,183 @@ func readPayload(r io.Reader) ([]byte, error) {
 	return b, nil
 }
 
-func sendJsonRequest(name string, st int, req *http.Request, jd interface{}) {
-	req.Header.Set("Content-Type", "application/json")
-	req.Header.Set("PRIVATE-TOKEN", *private_token)
-
-	res, err := http.DefaultClient.Do(req)
+func readOriginRemote() (*url.URL, error) {
+	result, err := readRemoteURL(*origin_remote)
 	if err != nil {
-		log.Fatalf("Couldn't execute %v against %s: %v", req.Method, req.URL, err)
-		return
-	}
-	defer res.Body.Close()
-	if res.StatusCode == st {
-		if jd != nil {
-			d := json.NewDecoder(res.Body)
-			err = d.Decode(jd)
-			if err != nil {
-				log.Fatalf("Error decoding json payload %v", err)
-			}
-		}
-		return
+		return nil, err
 	}
 
-	log.Fatalf("Couldn't execute %v against %s: %v", req.Method, req.URL, httputil.HTTPError(res))
-}
-
-func getURL(path string) string {
-	return fmt.Sprintf("%v/%v/%v", *address, *api_path, path)
+	result.User = nil
+	result.RawQuery = ""
+	result.Fragment = ""
+	return result, nil
 }
 
-func groups() []*Group {
-	url := getURL(groups_url)
-	req, err := http.NewRequest("GET", url, nil)
-	if err != nil {
-		log.Fatalf("Failed to create NewRequest", err)
+func groupName(group_data *gitlab.Group) string {
+	if group_data == nil {
+		return "user namespace"
 	}
-
-	var groups []*Group
-	sendJsonRequest("get groups", 200, req, &groups)
-	return groups
+	return group_data.NamespacePath()
 }
 
-func findGroup(name string) *Group {
-	if name == "" {
-		return nil
+func findOrCreateSubgroup(parent *gitlab.Group, name string) (*gitlab.Group, error) {
+	path := name
+	new_group := gitlab.CreateGroup{Name: name, Path: name, Visibility: *visibility_level}
+	if parent != nil {
+		path = parent.NamespacePath() + "/" + name
+		new_group.ParentId = parent.Id
 	}
 
-	groups := groups()
-
-	for _, group := range groups {
-		if group.Name == name {
-			return group
+	log.Printf("Looking for group %v...", path)
+	group_data, err := client.GetGroup(path)
+	if gitlab.IsNotFound(err) {
+		log.Printf("Creating group %v in %v...", name, groupName(parent))
+		group_data, err = client.CreateGroup(new_group)
+		if gitlab.IsAlreadyExists(err) {
+			group_data, err = client.GetGroup(path)
 		}
 	}
-	return nil
-}
-
-func projects() []*Project {
-	url := getURL(projects_url)
-	req, err := http.NewRequest("GET", url, nil)
 	if err != nil {
-		log.Fatalf("Failed to create NewRequest", err)
+		return nil, fmt.Errorf("failed to find or create group %v: %v", path, err)
 	}
-
-	var projects []*Project
-	sendJsonRequest("get groups", 200, req, &projects)
-	return projects
+	return group_data, nil
 }
 
-func findProject(namespace string, project_name string) *Project {
-	projects := projects()
+// resolveGroup looks up the group by full path or name. Missing
+// subgroups are created on demand, but top-level groups never are.
+func resolveGroup(path string) (*gitlab.Group, error) {
+	if path == "" {
+		return nil, nil
+	}
 
-	for _, project := range projects {
-		// this is hack for project of existing name
-		nsMatch := project.Namespace.Name == namespace || namespace == ""
-		if nsMatch && project.Name == project_name {
-			return project
-		}
+	log.Printf("Looking for group %v...", path)
+	group_data, err := client.FindGroup(path)
+	if err == nil {
+		return group_data, nil
+	} else if !gitlab.IsNotFound(err) {
+		return nil, err
 	}
-	return nil
-}
 
-func createProject(new_project CreateProject) Project {
-	body, err := json.Marshal(&new_project)
+	i := strings.LastIndex(path, "/")
+	if i < 0 {
+		return nil, fmt.Errorf("no group %v found", path)
+	}
+	parent, err := resolveGroup(path[:i])
 	if err != nil {
-		log.Fatalf("Failed to marshal project object: %v", err)
+		return nil, err
 	}
+	return findOrCreateSubgroup(parent, path[i+1:])
+}
 
-	url := getURL(projects_url)
-	req, err := http.NewRequest("POST", url, bytes.NewReader(body))
+func namespacePath(group_data *gitlab.Group) (string, error) {
+	if group_data != nil {
+		return group_data.NamespacePath(), nil
+	}
+	user, err := client.CurrentUser()
 	if err != nil {
-		log.Fatalf("Failed to create NewRequest", err)
+		return "", err
 	}
+	return user.Username, nil
+}
 
-	req.Header.Set("Content-Type", "application/json")
-	req.ContentLength = int64(len(body))
+// upstreamMarker records the upstream at the end of the description
+// of a project, so the project is known to mirror it. Markdown
+// rendering hides it.
+var upstreamMarker = regexp.MustCompile(`\s*<!-- mirror of (\S+) -->\s*$`)
 
-	var created_project Project
-	sendJsonRequest("create project", 201, req, &created_project)
-	return created_project
+func markDescription(description string, upstream string) string {
+	marker := fmt.Sprintf("<!-- mirror of %v -->", upstream)
+	description = upstreamMarker.ReplaceAllString(description, "")
+	if description == "" {
+		return marker
+	}
+	return description + "\n\n" + marker
 }
 
-func readOriginRemote() url.URL {
-	out, err := exec.Command(*git, "config", fmt.Sprintf("remote.%v.url", *origin_remote)).Output()
-	if err != nil {
-		log.Fatalf("No URL defined for %v.", *origin_remote)
+// descriptionUpstream returns the upstream recorded in the
+// description, empty if there is none.
+func descriptionUpstream(description string) string {
+	match := upstreamMarker.FindStringSubmatch(description)
+	if match == nil {
+		return ""
 	}
+	return match[1]
+}
 
-	rawurl := strings.TrimSpace(string(out))
-	if !strings.Contains(rawurl, "://") {
-		rawurl = strings.Replace(rawurl, ":", "/", 1)
-		rawurl = "ssh://" + rawurl
+// projectUpstream returns the upstream recorded in the description,
+// empty for projects created before it was recorded.
+func projectUpstream(project_data *gitlab.Project) string {
+	return descriptionUpstream(project_data.Description)
+}
+
+// isMirrorOf tells if the project mirrors the target. Projects created
+// before the upstream was recorded can only be matched by name.
+func isMirrorOf(project_data *gitlab.Project, target *mirrorTarget) bool {
+	upstream := projectUpstream(project_data)
+	if upstream == "" {
+		return project_data.Name == target.Name
 	}
+	return strings.EqualFold(upstream, target.Upstream)
+}
 
-	result, err := url.Parse(rawurl)
+// findMirrorProject looks up the project by path. A project using that
+// path that doesn't mirror the target mirrors another repository, so
+// the collision path and name are tried next. Projects created before
+// paths were set explicitly are found by their name.
+func findMirrorProject(group_data *gitlab.Group, target *mirrorTarget) (*gitlab.Project, error) {
+	namespace_path, err := namespacePath(group_data)
 	if err != nil {
-		log.Fatalf("Invalid URL for %v: %s", *origin_remote, out)
+		return nil, err
 	}
 
-	result.User = nil
-	result.RawQuery = ""
-	result.Fragment = ""
-	return *result
-}
-
-func doCreate(repo_name string, repo_url string) *Project {
-	log.Printf("Looking for group %v...", *group)
-	group_data := findGroup(*group)
-	if group_data == nil && *group != "" {
-		log.Fatalf("No group %v found.", *group)
+	paths := []string{target.Path, target.collisionPath()}
+	names := []string{target.Name, target.collisionName()}
+	for i, path := range paths {
+		project, err := client.GetProject(namespace_path + "/" + path)
+		if gitlab.IsNotFound(err) {
+			project, err = client.FindProject(group_data, names[i])
+			if gitlab.IsNotFound(err) {
+				target.Path, target.Name = path, names[i]
+				return nil, err
+			}
+		}
+		if err != nil {
+			return nil, err
+		}
+		if isMirrorOf(project, target) {
+			return project, nil
+		}
+		owner := projectUpstream(project)
+		if owner == "" {
+			owner = project.Name
+		}
+		log.Printf("Project %v is already used by %v...", project.PathWithNamespace, owner)
 	}
+	return nil, fmt.Errorf("paths %v or names %v are already used in %v",
+		strings.Join(paths, ", "), strings.Join(names, ", "), namespace_path)
+}
 
-	log.Printf("Creating project %v in %v...", repo_name, *group)
-	new_project := CreateProject{}
+func createProject(group_data *gitlab.Group, target *mirrorTarget) (*gitlab.Project, error) {
+	repo_name := target.Name
+	log.Printf("Creating project %v in %v...", repo_name, groupName(group_data))
+	new_project := gitlab.CreateProject{}
 	new_project.Name = repo_name
-	new_project.Description = fmt.Sprintf("Mirror of %v", repo_url)
-	new_project.IssuesEnabled = false
-	new_project.MergeRequestsEnabled = false
-	new_project.WikiEnabled = false
-	new_project.SnippetsEnabled = false
+	new_project.Path = target.Path
+	new_project.Description = markDescription(target.Description, target.Upstream)
+	target.Settings.apply(&new_project)
 	if group_data != nil {
 		new_project.NamespaceId = group_data.Id
 	}
-	switch *visibility_level {
-	case "private":
-		new_project.VisibilityLevel = 0
-	case "internal":
-		new_project.VisibilityLevel = 10
-	case "public":
-		new_project.VisibilityLevel = 20
-	default:
-		log.Fatalf("Unsupported visibility_level: %v", *visibility_level)
+	if _, ok := gitlab.VisibilityLevels[*visibility_level]; !ok {
+		return nil, fmt.Errorf("unsupported visibility_level: %v", *visibility_level)
+	}
+	new_project.Visibility = *visibility_level
+	created_project, err := client.CreateProject(new_project)
+	if gitlab.IsAlreadyExists(err) {
+		// a retried request could have created it already
+		return findMirrorProject(group_data, target)
 	}
-	created_project := createProject(new_project)
-	return &created_project
+	return created_project, err
 }
 
 func doCheckRemote() bool {
@@ -258,57 +296,246 @@ func doCheckRemote() bool {
 	return true
 }
 
-func doCreateRemote() {
-	repo_url := readOriginRemote()
-	repo_name := repo_url.Path
-	repo_name = strings.TrimPrefix(repo_name, "/")
-	repo_name = strings.TrimSuffix(repo_name, ".git")
-	repo_name = strings.TrimPrefix(repo_name, *group+"/")
-	repo_name = strings.Replace(repo_name, "/", "-", -1)
-	repo_name = strings.TrimPrefix(repo_name, *trim_name)
+// doFindRemoteProject returns the project the GitLab remote points to.
+// The project id stored along the remote is preferred over its URL,
+// so the project is found after it was moved or renamed.
+func doFindRemoteProject() *gitlab.Project {
+	if remote_project != nil {
+		return remote_project
+	}
 
-	log.Printf("Looking for project %v in %v...", repo_name, *group)
-	project_data := findProject(*group, repo_name)
-	if project_data == nil {
-		project_data = doCreate(repo_name, repo_url.String())
+	id := getGitConfig(remoteConfigKey(*gitlab_remote, "project-id"))
+	if id != "" {
+		project_data, err := client.GetProject(id)
+		if err != nil {
+			log.Fatalf("Failed to find project %v of %v: %v", id, *gitlab_remote, err)
+		}
+		remote_project = project_data
+		return remote_project
 	}
 
-	log.Printf("Adding remote %v as %v...", project_data.SshRepoUrl, *gitlab_remote)
-	err := exec.Command(*git, "remote", "add", "--mirror=push", *gitlab_remote, project_data.SshRepoUrl).Run()
+	path, err := remoteProjectPath(*gitlab_remote)
 	if err != nil {
-		log.Fatalf("Failed to add git remote %v to %v", *gitlab_remote, project_data.SshRepoUrl)
+		log.Fatalf("Failed to find project of %v: %v", *gitlab_remote, err)
 	}
+	project_data, err := client.GetProject(path)
+	if err != nil {
+		log.Fatalf("Failed to find project %v: %v", path, err)
+	}
+	storeRemoteProject(project_data.Id)
+	remote_project = project_data
+	return remote_project
+}
 
-	log.Printf("We are waiting 3 seconds to settle down...")
-	time.Sleep(3000 * time.Millisecond)
+func storeRemoteProject(id int) {
+	err := setGitConfig(remoteConfigKey(*gitlab_remote, "project-id"), strconv.Itoa(id))
+	if err != nil {
+		log.Fatalf("Failed to store project id of %v: %v", *gitlab_remote, err)
+	}
 }
 
-func doPush() {
-	log.Printf("Pushing changes to %v...", *gitlab_remote)
-	cmd := exec.Command(*git, "push", *gitlab_remote)
-	cmd.Stdout = os.Stdout
-	cmd.Stderr = os.Stderr
-	err := cmd.Run()
+// doUpdateRemote points the GitLab remote at the current location of
+// its project, in case it was moved, renamed or the push transport changed.
+func doUpdateRemote(target *mirrorTarget) {
+	project_data := doFindRemoteProject()
+	upstream := projectUpstream(project_data)
+	if upstream != "" && !strings.EqualFold(upstream, target.Upstream) {
+		log.Fatalf("Project %v is a mirror of %v, not %v. Remove remote %v to mirror to a new project.",
+			project_data.PathWithNamespace, upstream, target.Upstream, *gitlab_remote)
+	}
+
+	remote_url := mirror_provider.PushURL(gitlabProject(project_data))
+	if getGitConfig(fmt.Sprintf("remote.%v.url", *gitlab_remote)) == remote_url {
+		return
+	}
+
+	log.Printf("Updating remote %v to %v...", *gitlab_remote, remote_url)
+	err := exec.Command(*git, "remote", "set-url", *gitlab_remote, remote_url).Run()
 	if err != nil {
-		log.Fatalf("Failed to push data to %v", *gitlab_remote)
+		log.Fatalf("Failed to update git remote %v to %v", *gitlab_remote, remote_url)
 	}
 }
 
+func doResolveTarget(upstream *upstreamRepository) *mirrorTarget {
+	repo_url, err := readOriginRemote()
+	if err != nil {
+		log.Fatalf("Failed to read %v remote: %v", *origin_remote, err)
+	}
+
+	target, err := resolveTarget(repo_url, upstream)
+	if err != nil {
+		log.Fatalf("Failed to resolve target of %v: %v", repo_url, err)
+	}
+	return target
+}
+
+func doCreateRemote(target *mirrorTarget) *mirrorProject {
+	namespace, err := mirror_provider.FindNamespace(target.Group)
+	if err != nil {
+		log.Fatalf("Failed to find namespace %v: %v", target.Group, err)
+	}
+
+	log.Printf("Looking for project %v in %v...", target.Name, namespaceName(namespace))
+	project_data, err := mirror_provider.FindProject(namespace, target)
+	if err != nil {
+		log.Fatalf("Failed to find project %v: %v", target.Name, err)
+	} else if project_data == nil {
+		project_data, err = mirror_provider.CreateProject(namespace, target)
+		if err != nil {
+			log.Fatalf("Failed to create project %v: %v", target.Name, err)
+		}
+	}
+
+	remote_url := mirror_provider.PushURL(project_data)
+	log.Printf("Adding remote %v as %v...", remote_url, *gitlab_remote)
+	err = exec.Command(*git, "remote", "add", "--mirror=push", *gitlab_remote, remote_url).Run()
+	if err != nil {
+		log.Fatalf("Failed to add git remote %v to %v", *gitlab_remote, remote_url)
+	}
+
+	storeRemoteProject(project_data.Id)
+
+	log.Printf("We are waiting 3 seconds to settle down...")
+	time.Sleep(3000 * time.Millisecond)
+	return project_data
+}
+
 func main() {
 	flag.Parse()
 
+	var err error
+	config, err = loadConfig(*config_path)
+	if err != nil {
+		log.Fatalf("Failed to load config: %v", err)
+	}
+
+	if *target != "" {
+		t := findTarget(*target)
+		if t == nil {
+			log.Fatalf("Unknown target: %v", *target)
+		}
+		t.apply()
+	} else if len(config.Targets) > 0 {
+		log.SetFlags(0)
+		doMirrorTargets()
+		return
+	}
+
 	if *address == "" {
 		log.Fatalf("Address is required!")
 	}
 	if *private_token == "" {
 		log.Fatalf("Private token is required!")
 	}
+	if *namespace_mode != "flat" && *namespace_mode != "nested" {
+		log.Fatalf("Unsupported namespace_mode: %v", *namespace_mode)
+	}
+	if *push_transport != "ssh" && *push_transport != "https" {
+		log.Fatalf("Unsupported push_transport: %v", *push_transport)
+	}
+	if *safe_mode != "off" && *safe_mode != "archive" && *safe_mode != "refuse" {
+		log.Fatalf("Unsupported safe_mode: %v", *safe_mode)
+	}
+	if *lfs != "on" && *lfs != "off" && *lfs != "auto" {
+		log.Fatalf("Unsupported lfs: %v", *lfs)
+	}
+	if *mirror_wiki && *deploy_keys && *push_transport == "ssh" {
+		// deploy keys give access only to the repository, not its wiki
+		log.Fatalf("Wikis can't be pushed with deploy keys, use push_transport https instead.")
+	}
+
+	ref_filter, err = mirrorRefFilter()
+	if err != nil {
+		log.Fatalf("Invalid ref patterns: %v", err)
+	}
+	doCheckGitVersion(ref_filter)
+	err = validateRefPatterns(splitPatterns(*protected_refs))
+	if err != nil {
+		log.Fatalf("Invalid protected ref patterns: %v", err)
+	}
 
 	log.SetFlags(0)
 
+	upstream := readUpstream()
+	target := doResolveTarget(upstream)
+
+	if *dry_run {
+		fmt.Println(target)
+		return
+	}
+
+	mirror_provider = newProvider()
+	deploy_key_manager, has_deploy_keys := mirror_provider.(deployKeyManager)
+	metadata_syncer, has_metadata := mirror_provider.(metadataSyncer)
+
+	switch flag.Arg(0) {
+	case "":
+	case "rotate-keys":
+		if !has_deploy_keys {
+			log.Fatalf("Deploy keys are not supported by %v.", *provider_name)
+		}
+		if !doCheckRemote() {
+			log.Fatalf("No git remote %v to rotate keys of.", *gitlab_remote)
+		}
+		deploy_key_manager.RotateKeys()
+		return
+	default:
+		log.Fatalf("Unknown command: %v", flag.Arg(0))
+	}
+
+	created := false
 	if !doCheckRemote() {
-		doCreateRemote()
+		doCreateRemote(target)
+		created = true
+	} else if updater, ok := mirror_provider.(remoteUpdater); ok {
+		updater.UpdateRemote(target)
+	}
+
+	if *sync_head {
+		doUpdateHead(upstream)
+	}
+
+	doConfigureRefs(*gitlab_remote, ref_filter)
+	if has_deploy_keys {
+		deploy_key_manager.EnsureDeployKey()
+	}
+	doCheckHostKey(*gitlab_remote)
+	doProtectRefs()
+
+	if lfsEnabled() {
+		doFetchLFS()
+		if enabler, ok := mirror_provider.(lfsEnabler); ok {
+			enabler.EnableLFS()
+		}
+		doPushLFS()
 	}
 
-	doPush()
+	err = doPush()
+	if err != nil && has_metadata && *sync_head && isPushRejected(err) {
+		// GitLab refuses to delete its default branch, so after
+		// upstream renamed it the push succeeds only once the
+		// default branch is switched to the new one
+		log.Printf("Push to %v was rejected, retrying after syncing default branch...", *gitlab_remote)
+		metadata_syncer.SyncMetadata(target, upstream)
+		err = doPush()
+	}
+	if err != nil {
+		log.Fatalf("Failed to push data to %v: %v", *gitlab_remote, err)
+	}
+
+	if has_metadata && (created || *sync_head || *sync_metadata && upstream != nil) {
+		metadata_syncer.SyncMetadata(target, upstream)
+	}
+
+	if archiver, ok := mirror_provider.(githubArchiver); ok && (*sync_releases || *sync_issues) {
+		archiver.SyncGitHub()
+	} else if *sync_releases || *sync_issues {
+		log.Printf("Releases and issues can't be copied to %v.", *provider_name)
+	}
+
+	if wiki, ok := mirror_provider.(wikiMirror); ok && *mirror_wiki {
+		wiki.MirrorWiki()
+	} else if *mirror_wiki {
+		log.Printf("Mirroring of wikis is not supported by %v.", *provider_name)
+	}
 }
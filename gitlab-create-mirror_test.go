@@ -0,0 +1,57 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/ayufan/gitlab-mirror-post-fetch/gitlab"
+)
+
+func TestMarkDescription(t *testing.T) {
+	tests := []struct {
+		description string
+		exp         string
+	}{
+		{"", "<!-- mirror of github.com/foo/bar -->"},
+		{"Mirror of foo", "Mirror of foo\n\n<!-- mirror of github.com/foo/bar -->"},
+		{"Mirror of foo\n\n<!-- mirror of github.com/foo/bar -->",
+			"Mirror of foo\n\n<!-- mirror of github.com/foo/bar -->"},
+		{"Mirror of foo\n\n<!-- mirror of github.com/old/bar -->",
+			"Mirror of foo\n\n<!-- mirror of github.com/foo/bar -->"},
+		{"<!-- mirror of github.com/old/bar -->", "<!-- mirror of github.com/foo/bar -->"},
+	}
+
+	for _, test := range tests {
+		got := markDescription(test.description, "github.com/foo/bar")
+		if got != test.exp {
+			t.Errorf("On %q, expected %q, got %q", test.description, test.exp, got)
+		}
+	}
+}
+
+func TestIsMirrorOf(t *testing.T) {
+	target := &mirrorTarget{Name: "a-b-c", Upstream: "github.com/a/b-c"}
+
+	tests := []struct {
+		name        string
+		description string
+		exp         bool
+	}{
+		{"a-b-c", markDescription("Mirror", "github.com/a/b-c"), true},
+		{"a-b-c", markDescription("Mirror", "GitHub.com/A/B-C"), true},
+		{"renamed", markDescription("Mirror", "github.com/a/b-c"), true},
+		// same name, different upstream
+		{"a-b-c", markDescription("Mirror", "github.com/a-b/c"), false},
+		{"a-b-c", markDescription("Mirror", "gitea.example.com/a/b-c"), false},
+		// created before the upstream was recorded
+		{"a-b-c", "Mirror of https://github.com/a/b-c.git", true},
+		{"other", "Mirror of https://github.com/a/b-c.git", false},
+		{"a-b-c", "mentions <!-- mirror of github.com/a-b/c --> inline", true},
+	}
+
+	for _, test := range tests {
+		project := &gitlab.Project{Name: test.name, Description: test.description}
+		if got := isMirrorOf(project, target); got != test.exp {
+			t.Errorf("On %q/%q, expected %v, got %v", test.name, test.description, test.exp, got)
+		}
+	}
+}
@@ -0,0 +1,166 @@
+// Package gitlab is a small client for the parts of the GitLab API
+// needed to look up and create mirror projects.
+package gitlab
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/ayufan/gitlab-mirror-post-fetch/apiutil"
+)
+
+const (
+	APIv3Path = "/api/v3"
+	APIv4Path = "/api/v4"
+
+	groupsURL   = "/groups"
+	projectsURL = "/projects"
+	userURL     = "/user"
+)
+
+// Client talks to a single GitLab instance on behalf of one user.
+type Client struct {
+	// URL is the address of the GitLab instance,
+	// e.g. https://gitlab.example.com/
+	URL string
+
+	// APIPath is the API prefix, e.g. /api/v4.
+	// Call DetectAPIPath to fill it in from the server.
+	APIPath string
+
+	PrivateToken string
+
+	// HTTPClient is used for all requests. Its Timeout applies
+	// to every attempt separately.
+	HTTPClient *http.Client
+
+	// Retry controls how failed requests are repeated.
+	apiutil.Retry
+
+	rateLimit apiutil.RateLimit
+}
+
+// NewClient creates a client for the GitLab instance at address.
+func NewClient(address string, privateToken string) *Client {
+	return &Client{
+		URL:          strings.TrimSuffix(address, "/"),
+		PrivateToken: privateToken,
+		HTTPClient:   &http.Client{Timeout: 30 * time.Second},
+		Retry:        apiutil.DefaultRetry,
+	}
+}
+
+// IsAPIv3 returns true if the client talks to the legacy v3 API.
+func (c *Client) IsAPIv3() bool {
+	return c.APIPath == APIv3Path
+}
+
+func (c *Client) apiURL(apiPath string, path string) string {
+	return strings.TrimSuffix(c.URL, "/") + apiPath + path
+}
+
+func (c *Client) getURL(path string) string {
+	return c.apiURL(c.APIPath, path)
+}
+
+// DetectAPIPath probes the server for the newest API version that
+// answers for our private token and stores it in APIPath.
+func (c *Client) DetectAPIPath() error {
+	for _, apiPath := range []string{APIv4Path, APIv3Path} {
+		_, err := c.send("GET", c.apiURL(apiPath, userURL), nil, 200, nil)
+		if err == nil {
+			c.APIPath = apiPath
+			return nil
+		} else if !IsNotFound(err) {
+			return err
+		}
+	}
+	return fmt.Errorf("no supported GitLab API found at %v", c.URL)
+}
+
+// send executes a JSON request against url and decodes the response
+// into jd. Failed attempts are retried with backoff. Unexpected
+// statuses are returned as *Error.
+func (c *Client) send(method string, url string, body interface{}, st int, jd interface{}) (http.Header, error) {
+	req, err := apiutil.NewRequest(method, url, body, st, jd)
+	if err != nil {
+		return nil, err
+	}
+	return c.sendRequest(req)
+}
+
+// sendData is send for a request body that is already encoded.
+func (c *Client) sendData(method string, url string, contentType string, data []byte, st int, jd interface{}) (http.Header, error) {
+	req := &apiutil.Request{Method: method, URL: url, Header: http.Header{}, Data: data, Status: st, Result: jd}
+	req.Header.Set("Content-Type", contentType)
+	return c.sendRequest(req)
+}
+
+func (c *Client) sendRequest(req *apiutil.Request) (http.Header, error) {
+	req.Header.Set("PRIVATE-TOKEN", c.PrivateToken)
+	return c.Do(req.Method, req.URL, func() (http.Header, bool, error) {
+		c.rateLimit.Wait(c.MaxRetryWait)
+		header, retry, err := req.Send(c.HTTPClient)
+		c.rateLimit.Track(header)
+		return header, retry, err
+	})
+}
+
+func (c *Client) get(path string, jd interface{}) error {
+	_, err := c.send("GET", c.getURL(path), nil, 200, jd)
+	return err
+}
+
+func (c *Client) post(path string, body interface{}, jd interface{}) error {
+	_, err := c.send("POST", c.getURL(path), body, 201, jd)
+	return err
+}
+
+func (c *Client) put(path string, body interface{}, jd interface{}) error {
+	_, err := c.send("PUT", c.getURL(path), body, 200, jd)
+	return err
+}
+
+func (c *Client) delete(path string) error {
+	_, err := c.send("DELETE", c.getURL(path), nil, 204, nil)
+	return err
+}
+
+// getPage fetches a single page into jd and returns the next page URL,
+// or an empty string after the last page.
+func (c *Client) getPage(url string, jd interface{}) (string, error) {
+	header, err := c.send("GET", url, nil, 200, jd)
+	if err != nil {
+		return "", err
+	}
+	return nextPage(url, header)
+}
+
+// nextPage returns URL of the page following current. Newer GitLab
+// versions skip X-Next-Page on large collections and only send Link
+// headers.
+func nextPage(current string, header http.Header) (string, error) {
+	if next := header.Get("X-Next-Page"); next != "" {
+		u, err := url.Parse(current)
+		if err != nil {
+			return "", err
+		}
+		query := u.Query()
+		query.Set("page", next)
+		u.RawQuery = query.Encode()
+		return u.String(), nil
+	}
+	return apiutil.ParseLink(header.Get("Link"))["next"], nil
+}
+
+func listQuery(search string) string {
+	query := url.Values{}
+	query.Set("per_page", "100")
+	if search != "" {
+		query.Set("search", search)
+	}
+	return "?" + query.Encode()
+}
@@ -0,0 +1,38 @@
+package gitlab
+
+import (
+	"net/http"
+	"testing"
+)
+
+func TestNextPage(t *testing.T) {
+	tests := []struct {
+		current string
+		header  http.Header
+		exp     string
+	}{
+		{"https://x/api/v4/groups?per_page=100", http.Header{}, ""},
+		{"https://x/api/v4/groups?per_page=100",
+			http.Header{"X-Next-Page": {"2"}},
+			"https://x/api/v4/groups?page=2&per_page=100"},
+		{"https://x/api/v4/groups?page=2&per_page=100",
+			http.Header{"X-Next-Page": {"3"}},
+			"https://x/api/v4/groups?page=3&per_page=100"},
+		// large collections have only Link headers
+		{"https://x/api/v4/projects?per_page=100",
+			http.Header{"Link": {`<https://x/api/v4/projects?id_after=42&per_page=100>; rel="next"`}},
+			"https://x/api/v4/projects?id_after=42&per_page=100"},
+		{"https://x/api/v4/projects?per_page=100",
+			http.Header{"X-Next-Page": {""}, "Link": {`<https://x/?page=1>; rel="first"`}},
+			""},
+	}
+
+	for _, test := range tests {
+		got, err := nextPage(test.current, test.header)
+		if err != nil {
+			t.Errorf("On %q with %v, unexpected error: %v", test.current, test.header, err)
+		} else if got != test.exp {
+			t.Errorf("On %q with %v, expected %q, got %q", test.current, test.header, test.exp, got)
+		}
+	}
+}
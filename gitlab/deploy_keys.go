@@ -0,0 +1,20 @@
+package gitlab
+
+import (
+	"fmt"
+)
+
+// AddDeployKey registers the key with the project with the given id.
+func (c *Client) AddDeployKey(projectId int, key DeployKey) (*DeployKey, error) {
+	var created DeployKey
+	err := c.post(fmt.Sprintf("%v/%d/deploy_keys", projectsURL, projectId), &key, &created)
+	if err != nil {
+		return nil, err
+	}
+	return &created, nil
+}
+
+// DeleteDeployKey removes the key from the project with the given id.
+func (c *Client) DeleteDeployKey(projectId int, keyId int) error {
+	return c.delete(fmt.Sprintf("%v/%d/deploy_keys/%d", projectsURL, projectId, keyId))
+}
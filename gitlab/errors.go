@@ -0,0 +1,40 @@
+package gitlab
+
+import (
+	"errors"
+	"strings"
+
+	"github.com/ayufan/gitlab-mirror-post-fetch/apiutil"
+)
+
+// ErrNotFound is returned by lookups that found no matching resource.
+var ErrNotFound = errors.New("not found")
+
+// Error describes a failed GitLab API request.
+type Error = apiutil.Error
+
+// IsNotFound returns true if the error is caused by a missing resource.
+func IsNotFound(err error) bool {
+	return err == ErrNotFound || apiutil.HasStatus(err, 404)
+}
+
+// IsAlreadyExists returns true if the error is caused by creating
+// a resource that already exists. Older GitLab versions report that
+// as a validation failure instead of a conflict.
+func IsAlreadyExists(err error) bool {
+	if apiutil.HasStatus(err, 409) {
+		return true
+	}
+	return apiutil.HasStatus(err, 400) && strings.Contains(err.Error(), "has already been taken")
+}
+
+// IsUnauthorized returns true if the private token was rejected
+// or lacks permissions for the request.
+func IsUnauthorized(err error) bool {
+	return apiutil.HasStatus(err, 401, 403)
+}
+
+// IsRateLimited returns true if the request was throttled by GitLab.
+func IsRateLimited(err error) bool {
+	return apiutil.HasStatus(err, 429)
+}
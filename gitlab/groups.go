@@ -0,0 +1,69 @@
+package gitlab
+
+import (
+	"errors"
+	"net/url"
+)
+
+// Groups lists all groups visible to the user matching search.
+func (c *Client) Groups(search string) ([]*Group, error) {
+	var groups []*Group
+	next := c.getURL(groupsURL) + listQuery(search)
+	for next != "" {
+		var page []*Group
+		var err error
+		next, err = c.getPage(next, &page)
+		if err != nil {
+			return nil, err
+		}
+		groups = append(groups, page...)
+	}
+	return groups, nil
+}
+
+// GetGroup returns the group with the given id or full path.
+func (c *Client) GetGroup(path string) (*Group, error) {
+	var group Group
+	err := c.get(groupsURL+"/"+url.PathEscape(path), &group)
+	if err != nil {
+		return nil, err
+	}
+	return &group, nil
+}
+
+// FindGroup looks up a group by full path, falling back to
+// searching for it by name.
+func (c *Client) FindGroup(name string) (*Group, error) {
+	group, err := c.GetGroup(name)
+	if err == nil {
+		return group, nil
+	} else if !IsNotFound(err) {
+		return nil, err
+	}
+
+	groups, err := c.Groups(name)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, group := range groups {
+		if group.Name == name || group.FullPath == name {
+			return group, nil
+		}
+	}
+	return nil, ErrNotFound
+}
+
+// CreateGroup creates a new group or subgroup.
+func (c *Client) CreateGroup(group CreateGroup) (*Group, error) {
+	if group.ParentId != 0 && c.IsAPIv3() {
+		return nil, errors.New("subgroups require GitLab API v4")
+	}
+
+	var created Group
+	err := c.post(groupsURL, &group, &created)
+	if err != nil {
+		return nil, err
+	}
+	return &created, nil
+}
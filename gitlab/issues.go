@@ -0,0 +1,47 @@
+package gitlab
+
+import (
+	"fmt"
+)
+
+// CreateIssue opens an issue in the project.
+func (c *Client) CreateIssue(projectId int, issue CreateIssue) (*Issue, error) {
+	var created Issue
+	err := c.post(fmt.Sprintf("%v/%d/issues", projectsURL, projectId), &issue, &created)
+	if err != nil {
+		return nil, err
+	}
+	return &created, nil
+}
+
+// EditIssue updates the issue with the given project-local id.
+func (c *Client) EditIssue(projectId int, issueIid int, issue EditIssue) (*Issue, error) {
+	var edited Issue
+	err := c.put(fmt.Sprintf("%v/%d/issues/%d", projectsURL, projectId, issueIid), &issue, &edited)
+	if err != nil {
+		return nil, err
+	}
+	return &edited, nil
+}
+
+// CreateIssueNote comments on the issue.
+func (c *Client) CreateIssueNote(projectId int, issueIid int, body string) (*Note, error) {
+	var created Note
+	note := map[string]string{"body": body}
+	err := c.post(fmt.Sprintf("%v/%d/issues/%d/notes", projectsURL, projectId, issueIid), &note, &created)
+	if err != nil {
+		return nil, err
+	}
+	return &created, nil
+}
+
+// EditIssueNote replaces the body of the comment.
+func (c *Client) EditIssueNote(projectId int, issueIid int, noteId int, body string) (*Note, error) {
+	var edited Note
+	note := map[string]string{"body": body}
+	err := c.put(fmt.Sprintf("%v/%d/issues/%d/notes/%d", projectsURL, projectId, issueIid, noteId), &note, &edited)
+	if err != nil {
+		return nil, err
+	}
+	return &edited, nil
+}
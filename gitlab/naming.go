@@ -0,0 +1,85 @@
+package gitlab
+
+import (
+	"regexp"
+	"strings"
+	"unicode"
+)
+
+var (
+	invalidPathChars   = regexp.MustCompile(`[^a-zA-Z0-9_.\-]+`)
+	repeatedPathChars  = regexp.MustCompile(`[_.\-]{2,}`)
+	reservedPathSuffix = regexp.MustCompile(`(?i)(\.git|\.atom)+$`)
+
+	// transliterations of common accented letters, everything
+	// else outside of ASCII is replaced with a dash
+	pathTransliterations = strings.NewReplacer(
+		"à", "a", "á", "a", "â", "a", "ã", "a", "ä", "a", "å", "a", "ą", "a",
+		"ç", "c", "ć", "c", "č", "c", "ď", "d",
+		"è", "e", "é", "e", "ê", "e", "ë", "e", "ę", "e", "ě", "e",
+		"ì", "i", "í", "i", "î", "i", "ï", "i",
+		"ł", "l", "ñ", "n", "ń", "n", "ň", "n",
+		"ò", "o", "ó", "o", "ô", "o", "õ", "o", "ö", "o", "ø", "o",
+		"ř", "r", "ś", "s", "š", "s", "ß", "ss", "ť", "t",
+		"ù", "u", "ú", "u", "û", "u", "ü", "u", "ů", "u",
+		"ý", "y", "ÿ", "y", "ź", "z", "ż", "z", "ž", "z",
+		"À", "A", "Á", "A", "Â", "A", "Ã", "A", "Ä", "A", "Å", "A", "Ą", "A",
+		"Ç", "C", "Ć", "C", "Č", "C", "Ď", "D",
+		"È", "E", "É", "E", "Ê", "E", "Ë", "E", "Ę", "E", "Ě", "E",
+		"Ì", "I", "Í", "I", "Î", "I", "Ï", "I",
+		"Ł", "L", "Ñ", "N", "Ń", "N", "Ň", "N",
+		"Ò", "O", "Ó", "O", "Ô", "O", "Õ", "O", "Ö", "O", "Ø", "O",
+		"Ř", "R", "Ś", "S", "Š", "S", "Ť", "T",
+		"Ù", "U", "Ú", "U", "Û", "U", "Ü", "U", "Ů", "U",
+		"Ý", "Y", "Ź", "Z", "Ż", "Z", "Ž", "Z",
+	)
+)
+
+// SanitizePath turns a name into a path accepted by GitLab. Paths can
+// contain only letters, digits, '_', '-' and '.', cannot start or end
+// with a special character and cannot end in '.git' or '.atom'.
+func SanitizePath(name string) string {
+	path := pathTransliterations.Replace(name)
+	path = invalidPathChars.ReplaceAllString(path, "-")
+	path = repeatedPathChars.ReplaceAllString(path, "-")
+	for {
+		trimmed := strings.Trim(reservedPathSuffix.ReplaceAllString(path, ""), "_.-")
+		if trimmed == path {
+			break
+		}
+		path = trimmed
+	}
+	if path == "" {
+		path = "project"
+	}
+	return path
+}
+
+func isValidNameChar(r rune) bool {
+	return unicode.IsLetter(r) || unicode.IsDigit(r) || unicode.Is(unicode.So, r) ||
+		strings.ContainsRune("_.+- ", r)
+}
+
+func isValidNameStart(r rune) bool {
+	return unicode.IsLetter(r) || unicode.IsDigit(r) || unicode.Is(unicode.So, r) || r == '_'
+}
+
+// SanitizeName keeps a project name as close to the original as
+// GitLab allows: letters, digits, emojis, '_', '.', '+', dashes and
+// spaces, starting with a letter, digit, emoji or '_'.
+func SanitizeName(name string) string {
+	name = strings.Map(func(r rune) rune {
+		if isValidNameChar(r) {
+			return r
+		}
+		return '-'
+	}, name)
+	name = strings.TrimLeftFunc(name, func(r rune) bool {
+		return !isValidNameStart(r)
+	})
+	name = strings.TrimSpace(name)
+	if name == "" {
+		name = "project"
+	}
+	return name
+}
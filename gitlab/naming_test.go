@@ -0,0 +1,70 @@
+package gitlab
+
+import (
+	"testing"
+)
+
+func TestSanitizePath(t *testing.T) {
+	tests := []struct {
+		name string
+		exp  string
+	}{
+		{"foo", "foo"},
+		{"foo-bar_baz.qux", "foo-bar_baz.qux"},
+		{"foo bar", "foo-bar"},
+		{"foo/bar", "foo-bar"},
+		{"a..b", "a-b"},
+		{"a-_-b", "a-b"},
+		{"-lead", "lead"},
+		{".hidden", "hidden"},
+		{"foo.bar-", "foo.bar"},
+		{"foo.git", "foo"},
+		{"FOO.GIT", "FOO"},
+		{"x.atom.git", "x"},
+		{"x.git.", "x"},
+		{"über", "uber"},
+		{"Łódź", "Lodz"},
+		{"straße", "strasse"},
+		{"日本", "project"},
+		{"foo日本bar", "foo-bar"},
+		{"___", "project"},
+		{"", "project"},
+	}
+
+	for _, test := range tests {
+		got := SanitizePath(test.name)
+		if got != test.exp {
+			t.Errorf("On %q, expected %q, got %q", test.name, test.exp, got)
+		}
+	}
+}
+
+func TestSanitizeName(t *testing.T) {
+	tests := []struct {
+		name string
+		exp  string
+	}{
+		{"foo", "foo"},
+		{"Foo Bar", "Foo Bar"},
+		{"foo.git", "foo.git"},
+		{"a..b+c", "a..b+c"},
+		{"über", "über"},
+		{"日本", "日本"},
+		{"rocket 🚀", "rocket 🚀"},
+		{"_private", "_private"},
+		{"-lead", "lead"},
+		{".hidden", "hidden"},
+		{"foo/bar", "foo-bar"},
+		{"foo@bar!", "foo-bar-"},
+		{"  spaced  ", "spaced"},
+		{"...", "project"},
+		{"", "project"},
+	}
+
+	for _, test := range tests {
+		got := SanitizeName(test.name)
+		if got != test.exp {
+			t.Errorf("On %q, expected %q, got %q", test.name, test.exp, got)
+		}
+	}
+}
@@ -0,0 +1,102 @@
+package gitlab
+
+import (
+	"net/url"
+	"strconv"
+)
+
+// Projects lists all projects visible to the user matching search.
+func (c *Client) Projects(search string) ([]*Project, error) {
+	var projects []*Project
+	next := c.getURL(projectsURL) + listQuery(search)
+	for next != "" {
+		var page []*Project
+		var err error
+		next, err = c.getPage(next, &page)
+		if err != nil {
+			return nil, err
+		}
+		projects = append(projects, page...)
+	}
+	return projects, nil
+}
+
+// GetProject returns the project with the given id or full path.
+func (c *Client) GetProject(path string) (*Project, error) {
+	var project Project
+	err := c.get(projectsURL+"/"+url.PathEscape(path), &project)
+	if err != nil {
+		return nil, err
+	}
+	return &project, nil
+}
+
+// FindProject looks up a project by name in the group. If group is
+// nil, a project of that name in any namespace is returned.
+func (c *Client) FindProject(group *Group, name string) (*Project, error) {
+	if group != nil {
+		project, err := c.GetProject(group.NamespacePath() + "/" + name)
+		if err == nil && project.Name == name {
+			return project, nil
+		} else if err != nil && !IsNotFound(err) {
+			return nil, err
+		}
+	}
+
+	projects, err := c.Projects(name)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, project := range projects {
+		// this is hack for project of existing name
+		nsMatch := group == nil || project.Namespace.Id == group.Id
+		if nsMatch && project.Name == name {
+			return project, nil
+		}
+	}
+	return nil, ErrNotFound
+}
+
+// CreateProject creates a new project, translating the payload for
+// the legacy API if needed.
+func (c *Client) CreateProject(project CreateProject) (*Project, error) {
+	if project.TagList == nil {
+		project.TagList = project.Topics
+	}
+
+	var payload interface{} = &project
+	if c.IsAPIv3() {
+		payload = toCreateProjectV3(project)
+	}
+
+	var created Project
+	err := c.post(projectsURL, payload, &created)
+	if err != nil {
+		return nil, err
+	}
+	return &created, nil
+}
+
+// EditProject updates settings of the project with the given id.
+func (c *Client) EditProject(id int, project EditProject) (*Project, error) {
+	if c.IsAPIv3() {
+		// topics are not supported by the legacy API
+		project.Topics = nil
+		project.TagList = nil
+
+		if project.WikiAccessLevel != "" {
+			project.WikiEnabled = optionalEnabled(project.WikiAccessLevel)
+			project.WikiAccessLevel = ""
+		}
+	} else if project.TagList == nil {
+		project.TagList = project.Topics
+	}
+
+	var edited Project
+	err := c.put(projectsURL+"/"+strconv.Itoa(id), &project, &edited)
+	if err != nil {
+		return nil, err
+	}
+	return &edited, nil
+}
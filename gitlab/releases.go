@@ -0,0 +1,55 @@
+package gitlab
+
+import (
+	"bytes"
+	"fmt"
+	"mime/multipart"
+	"net/url"
+)
+
+// GetRelease returns the release of the tag.
+func (c *Client) GetRelease(projectId int, tagName string) (*Release, error) {
+	var release Release
+	err := c.get(fmt.Sprintf("%v/%d/releases/%v", projectsURL, projectId, url.PathEscape(tagName)), &release)
+	if err != nil {
+		return nil, err
+	}
+	return &release, nil
+}
+
+// CreateRelease creates a release, and its tag if needed.
+func (c *Client) CreateRelease(projectId int, release CreateRelease) (*Release, error) {
+	var created Release
+	err := c.post(fmt.Sprintf("%v/%d/releases", projectsURL, projectId), &release, &created)
+	if err != nil {
+		return nil, err
+	}
+	return &created, nil
+}
+
+// UploadFile uploads the file to the project, so it can be
+// linked from issues or releases.
+func (c *Client) UploadFile(projectId int, filename string, content []byte) (*Upload, error) {
+	var body bytes.Buffer
+	writer := multipart.NewWriter(&body)
+	part, err := writer.CreateFormFile("file", filename)
+	if err != nil {
+		return nil, err
+	}
+	_, err = part.Write(content)
+	if err != nil {
+		return nil, err
+	}
+	err = writer.Close()
+	if err != nil {
+		return nil, err
+	}
+
+	var upload Upload
+	_, err = c.sendData("POST", c.getURL(fmt.Sprintf("%v/%d/uploads", projectsURL, projectId)),
+		writer.FormDataContentType(), body.Bytes(), 201, &upload)
+	if err != nil {
+		return nil, err
+	}
+	return &upload, nil
+}
@@ -0,0 +1,229 @@
+package gitlab
+
+type Group struct {
+	Id       int    `json:"id,omitempty"`
+	Name     string `json:"name,omitempty"`
+	Path     string `json:"path,omitempty"`
+	FullPath string `json:"full_path,omitempty"`
+	OwnerId  int    `json:"owner_id,omitempty"`
+}
+
+// CreateProject is the API v4 payload for creating a project.
+type CreateProject struct {
+	Name                         string `json:"name,omitempty"`
+	Description                  string `json:"description,omitempty"`
+	Path                         string `json:"path,omitempty"`
+	IssuesAccessLevel            string `json:"issues_access_level,omitempty"`
+	MergeRequestsAccessLevel     string `json:"merge_requests_access_level,omitempty"`
+	WikiAccessLevel              string `json:"wiki_access_level,omitempty"`
+	SnippetsAccessLevel          string `json:"snippets_access_level,omitempty"`
+	BuildsAccessLevel            string `json:"builds_access_level,omitempty"`
+	ContainerRegistryAccessLevel string `json:"container_registry_access_level,omitempty"`
+	NamespaceId                  int    `json:"namespace_id,omitempty"`
+	Visibility                   string `json:"visibility,omitempty"`
+
+	// Topics replaced TagList in GitLab 14.0, both are sent
+	Topics  []string `json:"topics,omitempty"`
+	TagList []string `json:"tag_list,omitempty"`
+}
+
+// EditProject is the payload for updating a project.
+// Empty fields are left unchanged, topics are cleared
+// with a pointer to an empty list.
+type EditProject struct {
+	Description   string    `json:"description,omitempty"`
+	DefaultBranch string    `json:"default_branch,omitempty"`
+	Topics        *[]string `json:"topics,omitempty"`
+	TagList       *[]string `json:"tag_list,omitempty"`
+	LfsEnabled    *bool     `json:"lfs_enabled,omitempty"`
+
+	// WikiAccessLevel is sent as WikiEnabled to the legacy API
+	WikiAccessLevel string `json:"wiki_access_level,omitempty"`
+	WikiEnabled     *bool  `json:"wiki_enabled,omitempty"`
+}
+
+// createProjectV3 is the legacy API v3 payload for creating a project.
+type createProjectV3 struct {
+	Name                 string `json:"name,omitempty"`
+	Description          string `json:"description,omitempty"`
+	Path                 string `json:"path,omitempty"`
+	IssuesEnabled        bool   `json:"issues_enabled"`
+	MergeRequestsEnabled bool   `json:"merge_requests_enabled"`
+	WikiEnabled          bool   `json:"wiki_enabled"`
+	SnippetsEnabled      bool   `json:"snippets_enabled"`
+	BuildsEnabled        *bool  `json:"builds_enabled,omitempty"`
+	ContainerRegistry    *bool  `json:"container_registry_enabled,omitempty"`
+	NamespaceId          int    `json:"namespace_id,omitempty"`
+	VisibilityLevel      int    `json:"visibility_level"`
+}
+
+type Namespace struct {
+	Id          int    `json:"id,omitempty"`
+	Name        string `json:"name,omitempty"`
+	Description string `json:"description,omitempty"`
+	Path        string `json:"path,omitempty"`
+	FullPath    string `json:"full_path,omitempty"`
+	Kind        string `json:"kind,omitempty"`
+}
+
+type Project struct {
+	Id                int        `json:"id,omitempty"`
+	Name              string     `json:"name,omitempty"`
+	Description       string     `json:"description,omitempty"`
+	Public            bool       `json:"public,omitempty"`
+	Visibility        string     `json:"visibility,omitempty"`
+	VisibilityLevel   int        `json:"visibility_level,omitempty"`
+	Path              string     `json:"path,omitempty"`
+	PathWithNamespace string     `json:"path_with_namespace,omitempty"`
+	DefaultBranch     string     `json:"default_branch,omitempty"`
+	Topics            []string   `json:"topics,omitempty"`
+	TagList           []string   `json:"tag_list,omitempty"`
+	LfsEnabled        bool       `json:"lfs_enabled"`
+	WikiEnabled       bool       `json:"wiki_enabled"`
+	WikiAccessLevel   string     `json:"wiki_access_level,omitempty"`
+	WebUrl            string     `json:"web_url"`
+	SshRepoUrl        string     `json:"ssh_url_to_repo"`
+	HttpRepoUrl       string     `json:"http_url_to_repo"`
+	Namespace         *Namespace `json:"namespace"`
+}
+
+// VisibilityLevels maps v4 visibility names to v3 visibility levels.
+var VisibilityLevels = map[string]int{
+	"private":  0,
+	"internal": 10,
+	"public":   20,
+}
+
+// AccessLevels are the values accepted by *_access_level fields.
+var AccessLevels = []string{"disabled", "private", "enabled"}
+
+var accessLevelEnabled = map[string]bool{
+	"disabled": false,
+	"private":  true,
+	"enabled":  true,
+}
+
+// AccessLevelEnabled returns true if the access level
+// turns the feature on for at least some users.
+func AccessLevelEnabled(level string) bool {
+	return accessLevelEnabled[level]
+}
+
+// optionalEnabled translates an access level, if set, for API v3.
+func optionalEnabled(level string) *bool {
+	if level == "" {
+		return nil
+	}
+	enabled := accessLevelEnabled[level]
+	return &enabled
+}
+
+func toCreateProjectV3(project CreateProject) createProjectV3 {
+	return createProjectV3{
+		Name:                 project.Name,
+		Description:          project.Description,
+		Path:                 project.Path,
+		IssuesEnabled:        accessLevelEnabled[project.IssuesAccessLevel],
+		MergeRequestsEnabled: accessLevelEnabled[project.MergeRequestsAccessLevel],
+		WikiEnabled:          accessLevelEnabled[project.WikiAccessLevel],
+		SnippetsEnabled:      accessLevelEnabled[project.SnippetsAccessLevel],
+		BuildsEnabled:        optionalEnabled(project.BuildsAccessLevel),
+		ContainerRegistry:    optionalEnabled(project.ContainerRegistryAccessLevel),
+		NamespaceId:          project.NamespaceId,
+		VisibilityLevel:      VisibilityLevels[project.Visibility],
+	}
+}
+
+// NamespacePath returns the full path of the group, falling back
+// to its path on older GitLab versions.
+func (g *Group) NamespacePath() string {
+	if g.FullPath != "" {
+		return g.FullPath
+	}
+	return g.Path
+}
+
+// CreateGroup is the payload for creating a group. Subgroups
+// require API v4.
+type CreateGroup struct {
+	Name        string `json:"name"`
+	Path        string `json:"path"`
+	Description string `json:"description,omitempty"`
+	ParentId    int    `json:"parent_id,omitempty"`
+	Visibility  string `json:"visibility,omitempty"`
+}
+
+type User struct {
+	Id       int    `json:"id,omitempty"`
+	Username string `json:"username,omitempty"`
+	Name     string `json:"name,omitempty"`
+}
+
+type DeployKey struct {
+	Id      int    `json:"id,omitempty"`
+	Title   string `json:"title,omitempty"`
+	Key     string `json:"key,omitempty"`
+	CanPush bool   `json:"can_push"`
+}
+
+type Release struct {
+	TagName     string `json:"tag_name"`
+	Name        string `json:"name,omitempty"`
+	Description string `json:"description,omitempty"`
+}
+
+// CreateRelease is the payload for creating a release. Ref is used
+// only if the tag does not exist yet. Releases require API v4.
+type CreateRelease struct {
+	TagName     string         `json:"tag_name"`
+	Name        string         `json:"name,omitempty"`
+	Description string         `json:"description,omitempty"`
+	Ref         string         `json:"ref,omitempty"`
+	ReleasedAt  string         `json:"released_at,omitempty"`
+	Assets      *ReleaseAssets `json:"assets,omitempty"`
+}
+
+type ReleaseAssets struct {
+	Links []ReleaseLink `json:"links"`
+}
+
+type ReleaseLink struct {
+	Name string `json:"name"`
+	URL  string `json:"url"`
+}
+
+// Upload is a file uploaded to a project. URL is relative
+// to the web URL of the project.
+type Upload struct {
+	Alt      string `json:"alt"`
+	URL      string `json:"url"`
+	Markdown string `json:"markdown"`
+}
+
+type Issue struct {
+	Id          int    `json:"id"`
+	Iid         int    `json:"iid"`
+	Title       string `json:"title"`
+	Description string `json:"description"`
+	State       string `json:"state"`
+}
+
+type CreateIssue struct {
+	Title       string `json:"title"`
+	Description string `json:"description,omitempty"`
+	Labels      string `json:"labels,omitempty"`
+}
+
+// EditIssue is the payload for updating an issue. StateEvent
+// is close or reopen.
+type EditIssue struct {
+	Title       string `json:"title,omitempty"`
+	Description string `json:"description,omitempty"`
+	Labels      string `json:"labels,omitempty"`
+	StateEvent  string `json:"state_event,omitempty"`
+}
+
+type Note struct {
+	Id   int    `json:"id"`
+	Body string `json:"body"`
+}
@@ -0,0 +1,11 @@
+package gitlab
+
+// CurrentUser returns the user owning the private token.
+func (c *Client) CurrentUser() (*User, error) {
+	var user User
+	err := c.get(userURL, &user)
+	if err != nil {
+		return nil, err
+	}
+	return &user, nil
+}
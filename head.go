@@ -0,0 +1,68 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"log"
+	"os/exec"
+	"strings"
+)
+
+func branchExists(branch string) bool {
+	err := exec.Command(*git, "rev-parse", "--verify", "--quiet", "refs/heads/"+branch).Run()
+	return err == nil
+}
+
+// headBranch returns the branch HEAD of the repository points to.
+func headBranch() string {
+	out, err := exec.Command(*git, "symbolic-ref", "--quiet", "HEAD").Output()
+	if err != nil {
+		return ""
+	}
+	return strings.TrimPrefix(strings.TrimSpace(string(out)), "refs/heads/")
+}
+
+// remoteHeadBranch asks the remote which branch its HEAD points to.
+func remoteHeadBranch(remote string) string {
+	out, err := exec.Command(*git, "ls-remote", "--symref", remote, "HEAD").Output()
+	if err != nil {
+		log.Printf("Failed to read HEAD of %v: %v", remote, err)
+		return ""
+	}
+
+	scanner := bufio.NewScanner(bytes.NewReader(out))
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) == 3 && fields[0] == "ref:" && fields[2] == "HEAD" {
+			return strings.TrimPrefix(fields[1], "refs/heads/")
+		}
+	}
+	return ""
+}
+
+// doUpdateHead points HEAD of the mirror at the upstream default
+// branch. Fetching never updates HEAD of a mirror, so without it
+// renaming the default branch upstream would go unnoticed.
+func doUpdateHead(upstream *upstreamRepository) {
+	branch := ""
+	if upstream != nil {
+		branch = upstream.DefaultBranch
+	}
+	if branch == "" {
+		branch = remoteHeadBranch(*origin_remote)
+	}
+	if branch == "" || branch == headBranch() {
+		return
+	}
+
+	if !branchExists(branch) {
+		log.Printf("Branch %v doesn't exist, keeping HEAD at %v...", branch, headBranch())
+		return
+	}
+
+	log.Printf("Updating HEAD to %v...", branch)
+	err := exec.Command(*git, "symbolic-ref", "HEAD", "refs/heads/"+branch).Run()
+	if err != nil {
+		log.Fatalf("Failed to update HEAD to %v: %v", branch, err)
+	}
+}
@@ -0,0 +1,180 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"net"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+)
+
+// knownHostName is how the host appears in known_hosts.
+func knownHostName(host string, port string) string {
+	if port == "" || port == "22" {
+		return host
+	}
+	return fmt.Sprintf("[%v]:%v", host, port)
+}
+
+func hasHostKey(name string) bool {
+	err := exec.Command("ssh-keygen", "-F", name, "-f", *known_hosts).Run()
+	return err == nil
+}
+
+// configuredHostKeys returns known_hosts lines given with flags
+// and in the configuration file.
+func configuredHostKeys() []string {
+	var keys []string
+	for _, line := range strings.Split(*ssh_host_keys, "\n") {
+		if line = strings.TrimSpace(line); line != "" {
+			keys = append(keys, line)
+		}
+	}
+	return append(keys, config.SSHHostKeys...)
+}
+
+func readKnownHosts() (map[string]bool, error) {
+	lines := map[string]bool{}
+	data, err := ioutil.ReadFile(*known_hosts)
+	if os.IsNotExist(err) {
+		return lines, nil
+	} else if err != nil {
+		return nil, err
+	}
+
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for scanner.Scan() {
+		lines[strings.TrimSpace(scanner.Text())] = true
+	}
+	return lines, nil
+}
+
+func appendKnownHosts(lines []string) error {
+	err := os.MkdirAll(filepath.Dir(*known_hosts), 0700)
+	if err != nil {
+		return err
+	}
+
+	f, err := os.OpenFile(*known_hosts, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0600)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	for _, line := range lines {
+		_, err = fmt.Fprintln(f, line)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// pinHostKeys adds configured host keys missing in known_hosts.
+func pinHostKeys() error {
+	existing, err := readKnownHosts()
+	if err != nil {
+		return err
+	}
+
+	var missing []string
+	for _, key := range configuredHostKeys() {
+		if !existing[key] {
+			missing = append(missing, key)
+		}
+	}
+	if len(missing) == 0 {
+		return nil
+	}
+
+	log.Printf("Adding %d configured host keys to %v...", len(missing), *known_hosts)
+	return appendKnownHosts(missing)
+}
+
+// learnHostKey trusts the keys the host presents on first use.
+func learnHostKey(host string, port string) error {
+	if port == "" {
+		port = "22"
+	}
+
+	log.Printf("Learning host keys of %v...", knownHostName(host, port))
+	out, err := exec.Command("ssh-keyscan", "-p", port, host).Output()
+	if err != nil {
+		return fmt.Errorf("failed to scan host keys of %v: %v", host, err)
+	}
+
+	var lines []string
+	scanner := bufio.NewScanner(bytes.NewReader(out))
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line != "" && !strings.HasPrefix(line, "#") {
+			lines = append(lines, line)
+		}
+	}
+	if len(lines) == 0 {
+		return fmt.Errorf("no host keys found for %v", host)
+	}
+	return appendKnownHosts(lines)
+}
+
+// doCheckHostKey makes sure the host of the remote is known before
+// git talks to it. Host keys that changed are refused by ssh, as
+// StrictHostKeyChecking is always turned on.
+func doCheckHostKey(remote string) {
+	if *push_transport != "ssh" {
+		return
+	}
+
+	remote_url, err := readRemoteURL(remote)
+	if err != nil {
+		log.Fatalf("Failed to read %v remote: %v", remote, err)
+	}
+	if remote_url.Scheme != "ssh" {
+		return
+	}
+
+	err = pinHostKeys()
+	if err != nil {
+		log.Fatalf("Failed to update %v: %v", *known_hosts, err)
+	}
+
+	host, port := remote_url.Hostname(), remote_url.Port()
+	if hasHostKey(knownHostName(host, port)) {
+		return
+	}
+
+	if !*learn_host_keys {
+		log.Fatalf("Refusing to push to %v: no host key of %v in %v",
+			remote, net.JoinHostPort(host, port), *known_hosts)
+	}
+
+	err = learnHostKey(host, port)
+	if err != nil {
+		log.Fatalf("Refusing to push to %v: %v", remote, err)
+	}
+}
+
+// sshCommand is used by git for all ssh connections to GitLab.
+func sshCommand() string {
+	command := fmt.Sprintf("ssh -o UserKnownHostsFile=%v -o StrictHostKeyChecking=yes",
+		shellQuote(*known_hosts))
+	if *deploy_keys {
+		if key := deployKeyPath(); exists(key) {
+			command += fmt.Sprintf(" -i %v -o IdentitiesOnly=yes", shellQuote(key))
+		}
+	}
+	return command
+}
+
+func shellQuote(s string) string {
+	return "'" + strings.Replace(s, "'", `'\''`, -1) + "'"
+}
+
+func defaultKnownHosts() string {
+	return filepath.Join(os.Getenv("HOME"), ".ssh", "gitlab-mirror_known_hosts")
+}
@@ -0,0 +1,82 @@
+package main
+
+import (
+	"log"
+	"os"
+	"os/exec"
+
+	"github.com/ayufan/gitlab-mirror-post-fetch/gitlab"
+)
+
+func hasLFSCommand() bool {
+	return exec.Command(*git, "lfs", "version").Run() == nil
+}
+
+// usesLFS looks for LFS filters in .gitattributes of HEAD.
+func usesLFS() bool {
+	err := exec.Command(*git, "grep", "-q", "-F", "filter=lfs", "HEAD", "--",
+		".gitattributes", ":(glob)**/.gitattributes").Run()
+	return err == nil
+}
+
+// lfsEnabled decides if LFS objects are mirrored.
+func lfsEnabled() bool {
+	switch *lfs {
+	case "on":
+		if !hasLFSCommand() {
+			log.Fatalf("Git LFS is not installed.")
+		}
+		return true
+	case "auto":
+		if !usesLFS() {
+			return false
+		}
+		if !hasLFSCommand() {
+			log.Printf("Repository uses Git LFS, but it's not installed. LFS objects are not mirrored.")
+			return false
+		}
+		return true
+	}
+	return false
+}
+
+// doFetchLFS downloads LFS objects of all refs, as the mirror
+// clone has only the pointers.
+func doFetchLFS() {
+	log.Printf("Fetching LFS objects from %v...", *origin_remote)
+	cmd := exec.Command(*git, "lfs", "fetch", "--all", *origin_remote)
+	cmd.Stdout = os.Stderr
+	cmd.Stderr = os.Stderr
+	err := cmd.Run()
+	if err != nil {
+		log.Fatalf("Failed to fetch LFS objects from %v: %v", *origin_remote, err)
+	}
+}
+
+// doPushLFS uploads LFS objects ahead of the refs using them,
+// otherwise GitLab rejects the push if it checks LFS integrity.
+func doPushLFS() {
+	log.Printf("Pushing LFS objects to %v...", *gitlab_remote)
+	cmd := gitlabCommand("lfs", "push", "--all", *gitlab_remote)
+	cmd.Stdout = os.Stderr
+	cmd.Stderr = os.Stderr
+	err := cmd.Run()
+	if err != nil {
+		log.Fatalf("Failed to push LFS objects to %v: %v", *gitlab_remote, err)
+	}
+}
+
+func doEnableLFS() {
+	project_data := doFindRemoteProject()
+	if project_data.LfsEnabled {
+		return
+	}
+
+	log.Printf("Enabling LFS of %v...", project_data.PathWithNamespace)
+	enabled := true
+	edited, err := client.EditProject(project_data.Id, gitlab.EditProject{LfsEnabled: &enabled})
+	if err != nil {
+		log.Fatalf("Failed to enable LFS of %v: %v", project_data.PathWithNamespace, err)
+	}
+	remote_project = edited
+}
@@ -0,0 +1,270 @@
+package main
+
+import (
+	"bytes"
+	"crypto/sha1"
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"path"
+	"regexp"
+	"strings"
+	"text/template"
+
+	"github.com/ayufan/gitlab-mirror-post-fetch/gitlab"
+)
+
+// mirrorTarget describes where the mirror of a repository lives.
+type mirrorTarget struct {
+	// Group is the full path of the group, or empty for the
+	// namespace of the user.
+	Group       string
+	Name        string
+	Path        string
+	Description string
+
+	// URL is the upstream repository
+	URL string
+
+	// Upstream identifies the mirrored repository independently
+	// of the transport, e.g. github.com/foo/bar
+	Upstream string
+
+	Settings projectSettings
+}
+
+// mappingData is passed to the templates of mapping rules.
+type mappingData struct {
+	URL      string // origin URL, e.g. ssh://git@github.com/foo/bar.git
+	Host     string // e.g. github.com
+	FullName string // e.g. foo/bar
+	Name     string // e.g. bar
+	Owner    struct {
+		Login string // e.g. foo
+	}
+	Group string            // GITLAB_GROUP
+	Match map[string]string // named and numbered submatches of the rule
+
+	// from the webhook payload, if any
+	Description string
+	Homepage    string
+}
+
+// mappingRule rewrites repositories that match the regular expression
+// into a target. Empty templates keep the default value.
+type mappingRule struct {
+	Match       string `json:"match"`
+	Group       string `json:"group"`
+	Name        string `json:"name"`
+	Path        string `json:"path"`
+	Description string `json:"description"`
+
+	// Project overrides settings of created projects.
+	Project *projectSettings `json:"project"`
+
+	match       *regexp.Regexp
+	group       *template.Template
+	name        *template.Template
+	path        *template.Template
+	description *template.Template
+}
+
+func parseTemplate(name string, text string) (*template.Template, error) {
+	if text == "" {
+		return nil, nil
+	}
+	return template.New(name).Option("missingkey=zero").Parse(text)
+}
+
+func (r *mappingRule) compile() (err error) {
+	r.match, err = regexp.Compile(r.Match)
+	if err != nil {
+		return err
+	}
+	if r.group, err = parseTemplate("group", r.Group); err != nil {
+		return err
+	}
+	if r.name, err = parseTemplate("name", r.Name); err != nil {
+		return err
+	}
+	if r.path, err = parseTemplate("path", r.Path); err != nil {
+		return err
+	}
+	if r.description, err = parseTemplate("description", r.Description); err != nil {
+		return err
+	}
+	return r.Project.validate()
+}
+
+// subject is what rules are matched against, e.g. github.com/foo/bar
+func (d *mappingData) subject() string {
+	return d.Host + "/" + d.FullName
+}
+
+func (r *mappingRule) apply(data *mappingData, target *mirrorTarget) (bool, error) {
+	match := r.match.FindStringSubmatch(data.subject())
+	if match == nil {
+		return false, nil
+	}
+
+	data.Match = map[string]string{}
+	for i, name := range r.match.SubexpNames() {
+		data.Match[fmt.Sprint(i)] = match[i]
+		if name != "" {
+			data.Match[name] = match[i]
+		}
+	}
+
+	fields := []struct {
+		tmpl  *template.Template
+		value *string
+	}{
+		{r.group, &target.Group},
+		{r.name, &target.Name},
+		{r.path, &target.Path},
+		{r.description, &target.Description},
+	}
+	for _, field := range fields {
+		if field.tmpl == nil {
+			continue
+		}
+		var b bytes.Buffer
+		err := field.tmpl.Execute(&b, data)
+		if err != nil {
+			return false, err
+		}
+		*field.value = strings.TrimSpace(b.String())
+	}
+	return true, nil
+}
+
+func newMappingData(repo_url *url.URL, upstream *upstreamRepository) *mappingData {
+	data := &mappingData{
+		URL:   repo_url.String(),
+		Host:  repo_url.Host,
+		Group: *group,
+	}
+	if upstream != nil {
+		data.Description = upstream.Description
+		data.Homepage = upstream.Homepage
+	}
+	data.FullName = strings.TrimPrefix(repo_url.Path, "/")
+	data.FullName = strings.TrimSuffix(data.FullName, ".git")
+	data.Name = path.Base(data.FullName)
+	data.Owner.Login = path.Dir(data.FullName)
+	if data.Owner.Login == "." {
+		data.Owner.Login = ""
+	}
+	return data
+}
+
+// defaultTarget names the project from the repository path,
+// following the namespace mode.
+func defaultTarget(data *mappingData) *mirrorTarget {
+	target := &mirrorTarget{Group: *group, URL: data.URL, Upstream: data.subject()}
+
+	repo_path := strings.TrimPrefix(data.FullName, *group+"/")
+	subgroups, repo_name := splitRepoPath(repo_path)
+	if len(subgroups) > 0 {
+		target.Group = strings.Trim(target.Group+"/"+strings.Join(subgroups, "/"), "/")
+	}
+	target.Name = strings.TrimPrefix(repo_name, *trim_name)
+	return target
+}
+
+func renderTemplate(name string, text string, data interface{}) (string, error) {
+	tmpl, err := parseTemplate(name, text)
+	if err != nil || tmpl == nil {
+		return "", err
+	}
+	var b bytes.Buffer
+	err = tmpl.Execute(&b, data)
+	if err != nil {
+		return "", err
+	}
+	return b.String(), nil
+}
+
+// splitRepoPath turns the upstream repository path into the list of
+// subgroups and the project name according to the namespace mode.
+func splitRepoPath(repo_path string) ([]string, string) {
+	switch *namespace_mode {
+	case "nested":
+		parts := strings.Split(repo_path, "/")
+		return parts[:len(parts)-1], parts[len(parts)-1]
+	default:
+		return nil, strings.Replace(repo_path, "/", "-", -1)
+	}
+}
+
+// resolveTarget finds out where the repository should be mirrored,
+// applying the first matching rule over the defaults.
+func resolveTarget(repo_url *url.URL, upstream *upstreamRepository) (*mirrorTarget, error) {
+	data := newMappingData(repo_url, upstream)
+	target := defaultTarget(data)
+
+	target.Settings = defaultProjectSettings
+	target.Settings.Topics = nil
+	target.Settings.merge(config.Project)
+
+	for _, rule := range config.Rules {
+		matched, err := rule.apply(data, target)
+		if err != nil {
+			return nil, fmt.Errorf("failed to apply rule %v: %v", rule.Match, err)
+		} else if matched {
+			target.Settings.merge(rule.Project)
+			break
+		}
+	}
+
+	target.Settings.merge(flagProjectSettings())
+	if upstream != nil {
+		target.Settings.merge(&projectSettings{Topics: upstream.Topics})
+	}
+	err := target.Settings.validate()
+	if err != nil {
+		return nil, err
+	}
+
+	if target.Description == "" {
+		target.Description, err = renderTemplate("description", target.Settings.Description, data)
+		if err != nil {
+			return nil, fmt.Errorf("failed to render description: %v", err)
+		}
+	}
+
+	if target.Name == "" {
+		return nil, fmt.Errorf("empty project name for %v", data.URL)
+	}
+	target.Name = gitlab.SanitizeName(target.Name)
+	if target.Path == "" {
+		target.Path = target.Name
+	}
+	target.Path = gitlab.SanitizePath(target.Path)
+	return target, nil
+}
+
+// collisionSuffix is derived from the upstream repository, not its
+// URL, so every run picks the same one whatever the transport.
+func (t *mirrorTarget) collisionSuffix() string {
+	hash := sha1.Sum([]byte(strings.ToLower(t.Upstream)))
+	return fmt.Sprintf("-%x", hash[:3])
+}
+
+// collisionPath is used when the path is taken by a project mirroring
+// another repository.
+func (t *mirrorTarget) collisionPath() string {
+	return t.Path + t.collisionSuffix()
+}
+
+// collisionName goes along with collisionPath, as names of projects
+// have to be unique within the namespace as well.
+func (t *mirrorTarget) collisionName() string {
+	return t.Name + t.collisionSuffix()
+}
+
+func (t *mirrorTarget) String() string {
+	settings, _ := json.Marshal(&t.Settings)
+	return fmt.Sprintf("group=%q name=%q path=%q description=%q project=%s",
+		t.Group, t.Name, t.Path, t.Description, settings)
+}
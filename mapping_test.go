@@ -0,0 +1,65 @@
+package main
+
+import (
+	"net/url"
+	"testing"
+)
+
+func testTarget(t *testing.T, repo string) *mirrorTarget {
+	repo_url, err := url.Parse(repo)
+	if err != nil {
+		t.Fatalf("Failed to parse %v: %v", repo, err)
+	}
+	target := defaultTarget(newMappingData(repo_url, nil))
+	target.Path = target.Name
+	return target
+}
+
+func TestCollisionPath(t *testing.T) {
+	tests := []struct {
+		repo string
+		path string
+		name string
+	}{
+		{"https://github.com/a-b/c.git", "a-b-c-284af7", "a-b-c-284af7"},
+		{"https://github.com/a/b-c.git", "a-b-c-b37f4b", "a-b-c-b37f4b"},
+		{"https://gitea.example.com/a/b-c.git", "a-b-c-f45fcc", "a-b-c-f45fcc"},
+		// the transport doesn't change the suffix
+		{"ssh://git@github.com/a/b-c.git", "a-b-c-b37f4b", "a-b-c-b37f4b"},
+		{"https://github.com/a/b-c", "a-b-c-b37f4b", "a-b-c-b37f4b"},
+		{"https://github.com/A/B-C.git", "A-B-C-b37f4b", "A-B-C-b37f4b"},
+	}
+
+	for _, test := range tests {
+		target := testTarget(t, test.repo)
+		if got := target.collisionPath(); got != test.path {
+			t.Errorf("On %v, expected path %q, got %q", test.repo, test.path, got)
+		}
+		if got := target.collisionName(); got != test.name {
+			t.Errorf("On %v, expected name %q, got %q", test.repo, test.name, got)
+		}
+		// every run has to pick the same one
+		if again := testTarget(t, test.repo).collisionPath(); again != target.collisionPath() {
+			t.Errorf("On %v, collision path changed from %q to %q", test.repo, target.collisionPath(), again)
+		}
+	}
+}
+
+func TestTargetUpstream(t *testing.T) {
+	tests := []struct {
+		repo     string
+		upstream string
+	}{
+		{"https://github.com/a-b/c.git", "github.com/a-b/c"},
+		{"https://github.com/a/b-c.git", "github.com/a/b-c"},
+		{"ssh://github.com/a/b-c.git", "github.com/a/b-c"},
+		{"https://gitea.example.com/a/b-c", "gitea.example.com/a/b-c"},
+	}
+
+	for _, test := range tests {
+		target := testTarget(t, test.repo)
+		if target.Upstream != test.upstream {
+			t.Errorf("On %v, expected %q, got %q", test.repo, test.upstream, target.Upstream)
+		}
+	}
+}
@@ -0,0 +1,147 @@
+package main
+
+import (
+	"encoding/json"
+	"log"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/ayufan/gitlab-mirror-post-fetch/gitlab"
+)
+
+// upstreamRepository is the repository described by the webhook
+// payload that gitmirror passes on stdin.
+type upstreamRepository struct {
+	Description   string   `json:"description"`
+	Homepage      string   `json:"homepage"`
+	Topics        []string `json:"topics"`
+	DefaultBranch string   `json:"default_branch"`
+}
+
+// readStdin returns the webhook payload passed by gitmirror on stdin.
+func readStdin() []byte {
+	stat, err := os.Stdin.Stat()
+	if err != nil || stat.Mode()&os.ModeCharDevice != 0 {
+		return nil
+	}
+
+	data, err := readPayload(os.Stdin)
+	if err != nil {
+		log.Printf("Failed to read payload: %v", err)
+		return nil
+	}
+	return data
+}
+
+// readUpstream parses the payload from stdin. It returns nil when
+// there is none, eg. for updates triggered with GET.
+func readUpstream() *upstreamRepository {
+	data := readStdin()
+	if len(data) == 0 {
+		return nil
+	}
+
+	payload := struct {
+		Repository *upstreamRepository `json:"repository"`
+	}{}
+	err := json.Unmarshal(data, &payload)
+	if err != nil {
+		log.Printf("Ignoring payload: %v", err)
+		return nil
+	}
+	return payload.Repository
+}
+
+// remoteProjectPath returns the path of the project that the remote
+// points to, eg. Mirrors/foo-bar for git@gitlab.com:Mirrors/foo-bar.git
+func remoteProjectPath(remote string) (string, error) {
+	remote_url, err := readRemoteURL(remote)
+	if err != nil {
+		return "", err
+	}
+
+	path := strings.TrimPrefix(remote_url.Path, "/")
+	path = strings.TrimSuffix(path, ".git")
+	return path, nil
+}
+
+func sameTopics(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	a = append([]string(nil), a...)
+	b = append([]string(nil), b...)
+	sort.Strings(a)
+	sort.Strings(b)
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+func projectTopics(project *gitlab.Project) []string {
+	if project.Topics != nil {
+		return project.Topics
+	}
+	return project.TagList
+}
+
+// doSyncMetadata brings description, topics and default branch of
+// the project in line with upstream. The configured default branch
+// takes precedence over HEAD of the mirror and the upstream one.
+func doSyncMetadata(target *mirrorTarget, upstream *upstreamRepository) {
+	project_data := doFindRemoteProject()
+
+	edit := gitlab.EditProject{}
+	changed := false
+
+	sync_upstream := *sync_metadata && upstream != nil
+
+	// the upstream marker is added to projects created without it
+	description := project_data.Description
+	if sync_upstream {
+		description = target.Description
+	}
+	description = markDescription(description, target.Upstream)
+	if project_data.Description != description {
+		edit.Description = description
+		changed = true
+	}
+
+	// payloads without topics leave them alone, but an
+	// empty list removes the topics of the project
+	topics := append([]string{}, target.Settings.Topics...)
+	if sync_upstream && upstream.Topics != nil && !sameTopics(projectTopics(project_data), topics) {
+		edit.Topics = &topics
+		changed = true
+	}
+
+	branch := target.Settings.DefaultBranch
+	if branch == "" && *sync_head {
+		branch = headBranch()
+	} else if branch == "" && sync_upstream {
+		branch = upstream.DefaultBranch
+	}
+	if branch != "" && branch != project_data.DefaultBranch {
+		if branchExists(branch) {
+			edit.DefaultBranch = branch
+			changed = true
+		} else {
+			log.Printf("Branch %v doesn't exist, keeping default branch of %v...", branch, project_data.PathWithNamespace)
+		}
+	}
+
+	if !changed {
+		return
+	}
+
+	log.Printf("Updating metadata of %v...", project_data.PathWithNamespace)
+	edited, err := client.EditProject(project_data.Id, edit)
+	if err != nil {
+		log.Fatalf("Failed to update metadata of %v: %v", project_data.PathWithNamespace, err)
+	}
+	remote_project = edited
+}
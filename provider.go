@@ -0,0 +1,193 @@
+package main
+
+import (
+	"log"
+
+	"github.com/ayufan/gitlab-mirror-post-fetch/gitlab"
+)
+
+// mirrorNamespace is a group, organization or user owning mirrors.
+type mirrorNamespace struct {
+	Id   int
+	Name string
+	Path string
+}
+
+// mirrorProject is a repository mirrors are pushed to.
+type mirrorProject struct {
+	Id      int
+	Path    string
+	SshURL  string
+	HttpURL string
+}
+
+// provider hides the API of the service mirrors are pushed to.
+// A nil namespace stands for the namespace of the user.
+type provider interface {
+	// FindNamespace returns the namespace of the given path,
+	// nil for an empty path.
+	FindNamespace(path string) (*mirrorNamespace, error)
+
+	// FindProject returns nil without an error
+	// if the target was not created yet.
+	FindProject(namespace *mirrorNamespace, target *mirrorTarget) (*mirrorProject, error)
+
+	CreateProject(namespace *mirrorNamespace, target *mirrorTarget) (*mirrorProject, error)
+
+	// PushURL returns the URL of the project for the push transport.
+	PushURL(project *mirrorProject) string
+}
+
+// Features only some services have are implemented through the
+// interfaces below. Like the do functions, their methods exit on errors.
+
+// remoteUpdater points the remote at the current location
+// of its project, in case it was moved or renamed.
+type remoteUpdater interface {
+	UpdateRemote(target *mirrorTarget)
+}
+
+// deployKeyManager pushes with a deploy key of the project
+// instead of the key of the account.
+type deployKeyManager interface {
+	EnsureDeployKey()
+	RotateKeys()
+}
+
+// lfsEnabler turns on LFS for the project before objects are pushed.
+type lfsEnabler interface {
+	EnableLFS()
+}
+
+// metadataSyncer edits the project to match the upstream
+// description, topics and default branch.
+type metadataSyncer interface {
+	SyncMetadata(target *mirrorTarget, upstream *upstreamRepository)
+}
+
+// githubArchiver copies releases and issues of GitHub repositories.
+type githubArchiver interface {
+	SyncGitHub()
+}
+
+// wikiMirror enables the wiki of the project and pushes the upstream one.
+type wikiMirror interface {
+	MirrorWiki()
+}
+
+func namespaceName(namespace *mirrorNamespace) string {
+	if namespace == nil {
+		return "user namespace"
+	}
+	return namespace.Path
+}
+
+// gitlabProvider mirrors to GitLab using the global client.
+type gitlabProvider struct{}
+
+func newGitLabProvider() provider {
+	client = gitlab.NewClient(*address, *private_token)
+	client.APIPath = *api_path
+	client.HTTPClient.Timeout = *timeout
+	client.MaxRetries = *retries
+	client.RetryWait = *retry_wait
+	client.MaxRetryWait = *max_retry_wait
+	if client.APIPath == "" {
+		log.Printf("Detecting GitLab API version...")
+		err := client.DetectAPIPath()
+		if err != nil {
+			log.Fatalf("Failed to detect GitLab API version: %v", err)
+		}
+	}
+	log.Printf("Using GitLab API at %v...", client.APIPath)
+	return &gitlabProvider{}
+}
+
+func gitlabGroup(namespace *mirrorNamespace) *gitlab.Group {
+	if namespace == nil {
+		return nil
+	}
+	return &gitlab.Group{Id: namespace.Id, Name: namespace.Name, FullPath: namespace.Path}
+}
+
+func gitlabProject(project_data *gitlab.Project) *mirrorProject {
+	return &mirrorProject{
+		Id:      project_data.Id,
+		Path:    project_data.PathWithNamespace,
+		SshURL:  project_data.SshRepoUrl,
+		HttpURL: project_data.HttpRepoUrl,
+	}
+}
+
+func (p *gitlabProvider) FindNamespace(path string) (*mirrorNamespace, error) {
+	group_data, err := resolveGroup(path)
+	if err != nil || group_data == nil {
+		return nil, err
+	}
+	return &mirrorNamespace{Id: group_data.Id, Name: group_data.Name, Path: group_data.NamespacePath()}, nil
+}
+
+func (p *gitlabProvider) FindProject(namespace *mirrorNamespace, target *mirrorTarget) (*mirrorProject, error) {
+	project_data, err := findMirrorProject(gitlabGroup(namespace), target)
+	if gitlab.IsNotFound(err) {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+	return gitlabProject(project_data), nil
+}
+
+func (p *gitlabProvider) CreateProject(namespace *mirrorNamespace, target *mirrorTarget) (*mirrorProject, error) {
+	project_data, err := createProject(gitlabGroup(namespace), target)
+	if err != nil {
+		return nil, err
+	}
+	return gitlabProject(project_data), nil
+}
+
+func (p *gitlabProvider) PushURL(project *mirrorProject) string {
+	if *push_transport == "https" {
+		return project.HttpURL
+	}
+	return project.SshURL
+}
+
+func (p *gitlabProvider) UpdateRemote(target *mirrorTarget) {
+	doUpdateRemote(target)
+}
+
+func (p *gitlabProvider) EnsureDeployKey() {
+	doEnsureDeployKey()
+}
+
+func (p *gitlabProvider) RotateKeys() {
+	doRotateKeys()
+}
+
+func (p *gitlabProvider) EnableLFS() {
+	doEnableLFS()
+}
+
+func (p *gitlabProvider) SyncMetadata(target *mirrorTarget, upstream *upstreamRepository) {
+	doSyncMetadata(target, upstream)
+}
+
+func (p *gitlabProvider) SyncGitHub() {
+	doSyncGitHub()
+}
+
+func (p *gitlabProvider) MirrorWiki() {
+	doMirrorWiki()
+}
+
+func newProvider() provider {
+	switch *provider_name {
+	case "gitlab":
+		return newGitLabProvider()
+	case "gitea":
+		return newGiteaProvider()
+	default:
+		log.Fatalf("Unsupported provider: %v", *provider_name)
+		return nil
+	}
+}
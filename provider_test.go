@@ -0,0 +1,31 @@
+package main
+
+import (
+	"testing"
+)
+
+func TestProviderFeatures(t *testing.T) {
+	tests := []struct {
+		provider provider
+		exp      bool
+	}{
+		{&gitlabProvider{}, true},
+		{&giteaProvider{}, false},
+	}
+
+	for _, test := range tests {
+		var p interface{} = test.provider
+		_, remote := p.(remoteUpdater)
+		_, keys := p.(deployKeyManager)
+		_, lfs := p.(lfsEnabler)
+		_, metadata := p.(metadataSyncer)
+		_, github := p.(githubArchiver)
+		_, wiki := p.(wikiMirror)
+		got := []bool{remote, keys, lfs, metadata, github, wiki}
+		for i, has := range got {
+			if has != test.exp {
+				t.Errorf("On %T, expected feature %d to be %v, got %v", test.provider, i, test.exp, has)
+			}
+		}
+	}
+}
@@ -0,0 +1,229 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"os/exec"
+	"strings"
+	"time"
+)
+
+type pushFailure int
+
+const (
+	pushFailed pushFailure = iota
+	pushTransient
+	pushUnauthorized
+	pushRejected
+	pushHostKey
+)
+
+func (f pushFailure) String() string {
+	switch f {
+	case pushTransient:
+		return "transient failure"
+	case pushUnauthorized:
+		return "authentication failure"
+	case pushRejected:
+		return "rejected refs"
+	case pushHostKey:
+		return "host key verification failure"
+	}
+	return "failure"
+}
+
+// pushFailurePatterns match git and ssh messages to the kind
+// of failure they describe. The first match wins.
+var pushFailurePatterns = []struct {
+	pattern string
+	failure pushFailure
+}{
+	{"REMOTE HOST IDENTIFICATION HAS CHANGED", pushHostKey},
+	{"Host key verification failed", pushHostKey},
+	{"Permission denied", pushUnauthorized},
+	{"Authentication failed", pushUnauthorized},
+	{"HTTP Basic: Access denied", pushUnauthorized},
+	{"could not read Username", pushUnauthorized},
+	{"Invalid username or password", pushUnauthorized},
+	{"You are not allowed to push", pushUnauthorized},
+	{"[rejected]", pushRejected},
+	{"[remote rejected]", pushRejected},
+	{"pre-receive hook declined", pushRejected},
+	{"Could not resolve host", pushTransient},
+	{"Connection timed out", pushTransient},
+	{"Connection refused", pushTransient},
+	{"Connection reset", pushTransient},
+	{"Connection closed", pushTransient},
+	{"Operation timed out", pushTransient},
+	{"The remote end hung up unexpectedly", pushTransient},
+	{"early EOF", pushTransient},
+	{"RPC failed", pushTransient},
+	{"502 Bad Gateway", pushTransient},
+	{"503 Service Unavailable", pushTransient},
+	{"504 Gateway Timeout", pushTransient},
+	{"failed to push some refs", pushRejected},
+}
+
+func classifyPushFailure(output string) pushFailure {
+	for _, p := range pushFailurePatterns {
+		if strings.Contains(output, p.pattern) {
+			return p.failure
+		}
+	}
+	return pushFailed
+}
+
+type pushError struct {
+	failure pushFailure
+	err     error
+}
+
+func (e *pushError) Error() string {
+	return fmt.Sprintf("%v: %v", e.failure, e.err)
+}
+
+func pushOnce(remote string) error {
+	args := []string{"push"}
+	if ref_filter.enabled() {
+		// a mirror prunes deleted refs on its own
+		args = append(args, "--prune")
+	}
+	args = append(args, remote)
+
+	var stderr bytes.Buffer
+	cmd := gitlabCommand(args...)
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = io.MultiWriter(os.Stderr, &stderr)
+	err := cmd.Run()
+	if err != nil {
+		return &pushError{classifyPushFailure(stderr.String()), err}
+	}
+	return nil
+}
+
+// pushWithRetries pushes to remote, repeating the push only
+// after failures that are likely to go away.
+func pushWithRetries(remote string) error {
+	for i := 0; ; i++ {
+		err := pushOnce(remote)
+		if err == nil {
+			return nil
+		}
+
+		pe, ok := err.(*pushError)
+		if !ok || pe.failure != pushTransient || i >= *push_retries {
+			return err
+		}
+
+		wait := *push_retry_wait << uint(i)
+		log.Printf("Push to %v failed with %v, retrying in %v...", remote, pe.failure, wait)
+		time.Sleep(wait)
+	}
+}
+
+// parseRefs reads "<sha> <ref>" lines as printed by ls-remote
+// and for-each-ref. Peeled tags and HEAD are skipped.
+func parseRefs(out []byte) map[string]string {
+	refs := map[string]string{}
+	scanner := bufio.NewScanner(bytes.NewReader(out))
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) != 2 {
+			continue
+		}
+		sha, ref := fields[0], fields[1]
+		if ref == "HEAD" || strings.HasSuffix(ref, "^{}") {
+			continue
+		}
+		refs[ref] = sha
+	}
+	return refs
+}
+
+func localRefs() (map[string]string, error) {
+	out, err := exec.Command(*git, "for-each-ref", "--format=%(objectname) %(refname)").Output()
+	if err != nil {
+		return nil, err
+	}
+	return parseRefs(out), nil
+}
+
+func remoteRefs(remote string) (map[string]string, error) {
+	out, err := gitlabCommand("ls-remote", remote).Output()
+	if err != nil {
+		return nil, err
+	}
+	return parseRefs(out), nil
+}
+
+// divergedRefs describes every local ref that differs on the remote
+// and every remote branch or tag that is gone locally. GitLab keeps
+// internal refs (merge requests, pipelines, ...) that are ignored,
+// as are refs that are not mirrored.
+func divergedRefs(local, remote map[string]string) []string {
+	var diverged []string
+	for ref, sha := range local {
+		if !ref_filter.matches(ref) {
+			continue
+		}
+		remoteSha, ok := remote[ref]
+		if !ok {
+			diverged = append(diverged, fmt.Sprintf("%v: missing on remote", ref))
+		} else if remoteSha != sha {
+			diverged = append(diverged, fmt.Sprintf("%v: %v locally, %v on remote", ref, sha, remoteSha))
+		}
+	}
+	for ref := range remote {
+		if _, ok := local[ref]; ok || !ref_filter.matches(ref) {
+			continue
+		}
+		if strings.HasPrefix(ref, "refs/heads/") || strings.HasPrefix(ref, "refs/tags/") {
+			diverged = append(diverged, fmt.Sprintf("%v: missing locally", ref))
+		}
+	}
+	return diverged
+}
+
+func verifyPush(remote string) error {
+	local, err := localRefs()
+	if err != nil {
+		return fmt.Errorf("failed to list local refs: %v", err)
+	}
+	remoteData, err := remoteRefs(remote)
+	if err != nil {
+		return fmt.Errorf("failed to list refs of %v: %v", remote, err)
+	}
+
+	diverged := divergedRefs(local, remoteData)
+	if len(diverged) == 0 {
+		return nil
+	}
+	for _, ref := range diverged {
+		log.Printf("Diverged ref %v", ref)
+	}
+	return fmt.Errorf("%d refs diverged on %v", len(diverged), remote)
+}
+
+func doPush() error {
+	log.Printf("Pushing changes to %v...", *gitlab_remote)
+	err := pushWithRetries(*gitlab_remote)
+	if err != nil {
+		return err
+	}
+
+	if !*verify_push {
+		return nil
+	}
+
+	log.Printf("Verifying refs of %v...", *gitlab_remote)
+	return verifyPush(*gitlab_remote)
+}
+
+func isPushRejected(err error) bool {
+	pe, ok := err.(*pushError)
+	return ok && pe.failure == pushRejected
+}
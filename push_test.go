@@ -0,0 +1,110 @@
+package main
+
+import (
+	"reflect"
+	"sort"
+	"testing"
+)
+
+func TestClassifyPushFailure(t *testing.T) {
+	tests := []struct {
+		output string
+		exp    pushFailure
+	}{
+		{"", pushFailed},
+		{"fatal: something unexpected\n", pushFailed},
+		{"To gitlab.com:Mirrors/foo.git\n" +
+			" ! [rejected]        main -> main (fetch first)\n" +
+			"error: failed to push some refs to 'gitlab.com:Mirrors/foo.git'\n", pushRejected},
+		{" ! [remote rejected] main -> main (pre-receive hook declined)\n" +
+			"error: failed to push some refs to 'gitlab.com:Mirrors/foo.git'\n", pushRejected},
+		{"error: failed to push some refs to 'gitlab.com:Mirrors/foo.git'\n", pushRejected},
+		// transient failures end with the same message as rejected refs
+		{"error: RPC failed; HTTP 502 curl 22 The requested URL returned error: 502\n" +
+			"fatal: the remote end hung up unexpectedly\n" +
+			"error: failed to push some refs to 'https://gitlab.com/Mirrors/foo.git'\n", pushTransient},
+		{"send-pack: unexpected disconnect while reading sideband packet\n" +
+			"fatal: early EOF\n" +
+			"error: failed to push some refs to 'gitlab.com:Mirrors/foo.git'\n", pushTransient},
+		{"ssh: Could not resolve hostname gitlab.com: Name or service not known\n" +
+			"fatal: Could not read from remote repository.\n", pushTransient},
+		{"fatal: unable to access 'https://gitlab.com/Mirrors/foo.git/': Could not resolve host: gitlab.com\n", pushTransient},
+		{"ssh: connect to host gitlab.com port 22: Connection timed out\n" +
+			"fatal: Could not read from remote repository.\n", pushTransient},
+		{"git@gitlab.com: Permission denied (publickey).\n" +
+			"fatal: Could not read from remote repository.\n", pushUnauthorized},
+		{"remote: HTTP Basic: Access denied\n" +
+			"fatal: Authentication failed for 'https://gitlab.com/Mirrors/foo.git/'\n", pushUnauthorized},
+		{"remote: GitLab: You are not allowed to push code to protected branches on this project.\n" +
+			" ! [remote rejected] main -> main (pre-receive hook declined)\n", pushUnauthorized},
+		{"@@@@@@@@@@@@@@@@@@@@@@@@@@@@@@@@@@@@@@@@@@@@@@@@@@@@@@@@@@@\n" +
+			"@    WARNING: REMOTE HOST IDENTIFICATION HAS CHANGED!     @\n" +
+			"Host key verification failed.\n" +
+			"fatal: Could not read from remote repository.\n", pushHostKey},
+		{"No ED25519 host key is known for gitlab.com and you have requested strict checking.\n" +
+			"Host key verification failed.\n", pushHostKey},
+	}
+
+	for _, test := range tests {
+		got := classifyPushFailure(test.output)
+		if got != test.exp {
+			t.Errorf("On %q, expected %v, got %v", test.output, test.exp, got)
+		}
+	}
+}
+
+func TestDivergedRefs(t *testing.T) {
+	defer func(filter *refFilter) { ref_filter = filter }(ref_filter)
+
+	local := map[string]string{
+		"refs/heads/main":  "aaa",
+		"refs/heads/dev":   "bbb",
+		"refs/heads/new":   "ccc",
+		"refs/tags/v1":     "ddd",
+		"refs/pull/1/head": "eee",
+	}
+	remote := map[string]string{
+		"refs/heads/main":                "aaa",
+		"refs/heads/dev":                 "bbb0",
+		"refs/tags/v1":                   "ddd",
+		"refs/tags/gone":                 "fff",
+		"refs/heads/gone":                "fff",
+		"refs/merge-requests/1/head":     "999",
+		"refs/pipelines/1":               "999",
+		"refs/keep-around/999":           "999",
+		"refs/mirror-archive/x/heads/qq": "999",
+	}
+
+	tests := []struct {
+		filter *refFilter
+		exp    []string
+	}{
+		{nil, []string{
+			"refs/heads/dev: bbb locally, bbb0 on remote",
+			"refs/heads/gone: missing locally",
+			"refs/heads/new: missing on remote",
+			"refs/pull/1/head: missing on remote",
+			"refs/tags/gone: missing locally",
+		}},
+		{&refFilter{Include: []string{"refs/heads/*", "refs/tags/*"}}, []string{
+			"refs/heads/dev: bbb locally, bbb0 on remote",
+			"refs/heads/gone: missing locally",
+			"refs/heads/new: missing on remote",
+			"refs/tags/gone: missing locally",
+		}},
+		{&refFilter{Include: []string{"refs/*"}, Exclude: []string{"refs/pull/*", "refs/heads/gone"}}, []string{
+			"refs/heads/dev: bbb locally, bbb0 on remote",
+			"refs/heads/new: missing on remote",
+			"refs/tags/gone: missing locally",
+		}},
+	}
+
+	for _, test := range tests {
+		ref_filter = test.filter
+		got := divergedRefs(local, remote)
+		sort.Strings(got)
+		if !reflect.DeepEqual(got, test.exp) {
+			t.Errorf("With %+v, expected %q, got %q", test.filter, test.exp, got)
+		}
+	}
+}
@@ -0,0 +1,187 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"os/exec"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+var gitVersion = regexp.MustCompile(`^git version (\d+)\.(\d+)`)
+
+// refFilter selects refs that are mirrored. Patterns are full ref
+// names that can contain a single *, eg. refs/heads/*
+type refFilter struct {
+	Include []string
+	Exclude []string
+}
+
+func splitPatterns(value string) []string {
+	var patterns []string
+	for _, pattern := range strings.Split(value, ",") {
+		pattern = strings.TrimSpace(pattern)
+		if pattern != "" {
+			patterns = append(patterns, pattern)
+		}
+	}
+	return patterns
+}
+
+func validateRefPatterns(patterns []string) error {
+	for _, pattern := range patterns {
+		if !strings.HasPrefix(pattern, "refs/") {
+			return fmt.Errorf("pattern %v has to start with refs/", pattern)
+		}
+		if strings.Count(pattern, "*") > 1 {
+			return fmt.Errorf("pattern %v can contain only one *", pattern)
+		}
+	}
+	return nil
+}
+
+// mirrorRefFilter merges the options with the configuration file,
+// options take precedence.
+func mirrorRefFilter() (*refFilter, error) {
+	filter := &refFilter{
+		Include: config.IncludeRefs,
+		Exclude: config.ExcludeRefs,
+	}
+	if patterns := splitPatterns(*include_refs); len(patterns) > 0 {
+		filter.Include = patterns
+	}
+	if patterns := splitPatterns(*exclude_refs); len(patterns) > 0 {
+		filter.Exclude = patterns
+	}
+
+	if err := validateRefPatterns(filter.Include); err != nil {
+		return nil, err
+	}
+	if err := validateRefPatterns(filter.Exclude); err != nil {
+		return nil, err
+	}
+	if *safe_mode == "archive" {
+		// pruning would remove the archive, as it's not there locally
+		filter.Exclude = append(filter.Exclude, archiveRefPrefix+"*")
+	}
+	if len(filter.Exclude) > 0 && len(filter.Include) == 0 {
+		filter.Include = []string{"refs/*"}
+	}
+	return filter, nil
+}
+
+func matchRef(pattern string, ref string) bool {
+	i := strings.Index(pattern, "*")
+	if i < 0 {
+		return pattern == ref
+	}
+	prefix, suffix := pattern[:i], pattern[i+1:]
+	return len(ref) >= len(prefix)+len(suffix) &&
+		strings.HasPrefix(ref, prefix) && strings.HasSuffix(ref, suffix)
+}
+
+// enabled returns false if all refs are mirrored.
+func (f *refFilter) enabled() bool {
+	return f != nil && len(f.Include) > 0
+}
+
+func (f *refFilter) matches(ref string) bool {
+	if !f.enabled() {
+		return true
+	}
+	for _, pattern := range f.Exclude {
+		if matchRef(pattern, ref) {
+			return false
+		}
+	}
+	for _, pattern := range f.Include {
+		if matchRef(pattern, ref) {
+			return true
+		}
+	}
+	return false
+}
+
+// refspecs are the push refspecs of the remote. Excluded refs use
+// negative refspecs, which need git 2.29 or newer.
+func (f *refFilter) refspecs() []string {
+	var refspecs []string
+	for _, pattern := range f.Include {
+		refspecs = append(refspecs, "+"+pattern+":"+pattern)
+	}
+	for _, pattern := range f.Exclude {
+		refspecs = append(refspecs, "^"+pattern)
+	}
+	return refspecs
+}
+
+// parseGitVersion reads the major and minor version from the output
+// of git version, eg. "git version 2.30.2".
+func parseGitVersion(out string) (int, int, error) {
+	match := gitVersion.FindStringSubmatch(strings.TrimSpace(out))
+	if match == nil {
+		return 0, 0, fmt.Errorf("unknown git version %q", strings.TrimSpace(out))
+	}
+	major, _ := strconv.Atoi(match[1])
+	minor, _ := strconv.Atoi(match[2])
+	return major, minor, nil
+}
+
+// doCheckGitVersion fails early if git can't push the negative
+// refspecs of excluded refs, rather than on every push.
+func doCheckGitVersion(filter *refFilter) {
+	if len(filter.Exclude) == 0 {
+		return
+	}
+
+	out, err := exec.Command(*git, "version").Output()
+	if err != nil {
+		log.Fatalf("Failed to read version of %v: %v", *git, err)
+	}
+	major, minor, err := parseGitVersion(string(out))
+	if err != nil {
+		log.Fatalf("Failed to read version of %v: %v", *git, err)
+	}
+	if major < 2 || major == 2 && minor < 29 {
+		log.Fatalf("Excluding refs, also done by safe_mode archive, needs git 2.29 or newer, %v is %d.%d.",
+			*git, major, minor)
+	}
+}
+
+// doConfigureRefs makes the remote push only the filtered refs. Without
+// a filter the remote is a plain mirror, as created by --mirror=push.
+func doConfigureRefs(remote string, filter *refFilter) {
+	push_key := fmt.Sprintf("remote.%v.push", remote)
+	mirror_key := fmt.Sprintf("remote.%v.mirror", remote)
+
+	mirror := "true"
+	refspecs := filter.refspecs()
+	if filter.enabled() {
+		mirror = "false"
+	}
+
+	out, _ := exec.Command(*git, "config", "--get-all", push_key).Output()
+	current := strings.Fields(string(out))
+	if getGitConfig(mirror_key) == mirror && strings.Join(current, " ") == strings.Join(refspecs, " ") {
+		return
+	}
+
+	if filter.enabled() {
+		log.Printf("Configuring %v to push %v...", remote, strings.Join(refspecs, " "))
+	} else {
+		log.Printf("Configuring %v to push all refs...", remote)
+	}
+
+	// --unset-all fails if there are no refspecs yet
+	exec.Command(*git, "config", "--unset-all", push_key).Run()
+	err := setGitConfig(mirror_key, mirror)
+	for _, refspec := range refspecs {
+		if err == nil {
+			err = exec.Command(*git, "config", "--add", push_key, refspec).Run()
+		}
+	}
+	if err != nil {
+		log.Fatalf("Failed to configure git remote %v: %v", remote, err)
+	}
+}
@@ -0,0 +1,160 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestMatchRef(t *testing.T) {
+	tests := []struct {
+		pattern string
+		ref     string
+		exp     bool
+	}{
+		{"refs/heads/main", "refs/heads/main", true},
+		{"refs/heads/main", "refs/heads/main2", false},
+		{"refs/heads/*", "refs/heads/main", true},
+		{"refs/heads/*", "refs/heads/feature/x", true},
+		{"refs/heads/*", "refs/tags/v1", false},
+		{"refs/heads/*", "refs/heads/", true},
+		{"refs/heads/tmp-*", "refs/heads/tmp-1", true},
+		{"refs/heads/tmp-*", "refs/heads/tmp", false},
+		{"refs/*/head", "refs/pull/1/head", true},
+		{"refs/*/head", "refs/pull/1/merge", false},
+		// prefix and suffix can't overlap
+		{"refs/heads/a*a", "refs/heads/a", false},
+		{"refs/heads/a*a", "refs/heads/aa", true},
+	}
+
+	for _, test := range tests {
+		got := matchRef(test.pattern, test.ref)
+		if got != test.exp {
+			t.Errorf("On %v with %v, expected %v, got %v", test.ref, test.pattern, test.exp, got)
+		}
+	}
+}
+
+func TestRefFilterMatches(t *testing.T) {
+	tests := []struct {
+		filter *refFilter
+		ref    string
+		exp    bool
+	}{
+		{nil, "refs/pull/1/head", true},
+		{&refFilter{}, "refs/pull/1/head", true},
+		{&refFilter{Include: []string{"refs/heads/*", "refs/tags/*"}}, "refs/heads/main", true},
+		{&refFilter{Include: []string{"refs/heads/*", "refs/tags/*"}}, "refs/tags/v1", true},
+		{&refFilter{Include: []string{"refs/heads/*", "refs/tags/*"}}, "refs/pull/1/head", false},
+		// excludes take precedence
+		{&refFilter{Include: []string{"refs/*"}, Exclude: []string{"refs/heads/tmp-*"}}, "refs/heads/tmp-1", false},
+		{&refFilter{Include: []string{"refs/*"}, Exclude: []string{"refs/heads/tmp-*"}}, "refs/heads/main", true},
+		{&refFilter{Include: []string{"refs/heads/main"}, Exclude: []string{"refs/heads/main"}}, "refs/heads/main", false},
+	}
+
+	for _, test := range tests {
+		got := test.filter.matches(test.ref)
+		if got != test.exp {
+			t.Errorf("On %v with %+v, expected %v, got %v", test.ref, test.filter, test.exp, got)
+		}
+	}
+}
+
+func TestRefFilterRefspecs(t *testing.T) {
+	tests := []struct {
+		filter *refFilter
+		exp    []string
+	}{
+		{&refFilter{}, nil},
+		{&refFilter{Include: []string{"refs/heads/*", "refs/tags/*"}},
+			[]string{"+refs/heads/*:refs/heads/*", "+refs/tags/*:refs/tags/*"}},
+		{&refFilter{Include: []string{"refs/*"}, Exclude: []string{"refs/pull/*", "refs/heads/tmp"}},
+			[]string{"+refs/*:refs/*", "^refs/pull/*", "^refs/heads/tmp"}},
+	}
+
+	for _, test := range tests {
+		got := test.filter.refspecs()
+		if !reflect.DeepEqual(got, test.exp) {
+			t.Errorf("With %+v, expected %q, got %q", test.filter, test.exp, got)
+		}
+	}
+}
+
+func TestMirrorRefFilter(t *testing.T) {
+	defer func(c *Config, include, exclude, mode string) {
+		config = c
+		*include_refs, *exclude_refs, *safe_mode = include, exclude, mode
+	}(config, *include_refs, *exclude_refs, *safe_mode)
+
+	tests := []struct {
+		config  Config
+		include string
+		exclude string
+		mode    string
+		exp     *refFilter
+	}{
+		{Config{}, "", "", "off", &refFilter{}},
+		{Config{}, "refs/heads/*, refs/tags/*", "", "off",
+			&refFilter{Include: []string{"refs/heads/*", "refs/tags/*"}}},
+		{Config{}, "", "refs/pull/*", "off",
+			&refFilter{Include: []string{"refs/*"}, Exclude: []string{"refs/pull/*"}}},
+		// options take precedence over the configuration file
+		{Config{IncludeRefs: []string{"refs/heads/*"}, ExcludeRefs: []string{"refs/heads/tmp"}}, "refs/tags/*", "", "off",
+			&refFilter{Include: []string{"refs/tags/*"}, Exclude: []string{"refs/heads/tmp"}}},
+		// the archive is never pruned
+		{Config{}, "", "", "archive",
+			&refFilter{Include: []string{"refs/*"}, Exclude: []string{"refs/mirror-archive/*"}}},
+		{Config{}, "refs/heads/*", "refs/heads/tmp", "archive",
+			&refFilter{Include: []string{"refs/heads/*"}, Exclude: []string{"refs/heads/tmp", "refs/mirror-archive/*"}}},
+		{Config{}, "", "", "refuse", &refFilter{}},
+	}
+
+	for _, test := range tests {
+		config = &test.config
+		*include_refs, *exclude_refs, *safe_mode = test.include, test.exclude, test.mode
+
+		got, err := mirrorRefFilter()
+		if err != nil {
+			t.Errorf("On %q/%q/%v, unexpected error: %v", test.include, test.exclude, test.mode, err)
+		} else if !reflect.DeepEqual(got, test.exp) {
+			t.Errorf("On %q/%q/%v, expected %+v, got %+v", test.include, test.exclude, test.mode, test.exp, got)
+		}
+	}
+
+	*safe_mode = "archive"
+	filter, _ := mirrorRefFilter()
+	if filter.matches("refs/mirror-archive/20160102T150405Z/heads/main") {
+		t.Errorf("Expected archived refs to be excluded from mirroring")
+	}
+
+	*include_refs, *exclude_refs = "heads/*", ""
+	if _, err := mirrorRefFilter(); err == nil {
+		t.Errorf("Expected error for pattern without refs/")
+	}
+	*include_refs, *exclude_refs = "", "refs/*/pull/*"
+	if _, err := mirrorRefFilter(); err == nil {
+		t.Errorf("Expected error for pattern with two *")
+	}
+}
+
+func TestParseGitVersion(t *testing.T) {
+	tests := []struct {
+		out   string
+		major int
+		minor int
+		err   bool
+	}{
+		{"git version 2.30.2\n", 2, 30, false},
+		{"git version 2.20.1", 2, 20, false},
+		{"git version 2.39.3 (Apple Git-145)\n", 2, 39, false},
+		{"git version 2.45.0.windows.1", 2, 45, false},
+		{"hub version 2.14.2", 0, 0, true},
+		{"", 0, 0, true},
+	}
+
+	for _, test := range tests {
+		major, minor, err := parseGitVersion(test.out)
+		if (err != nil) != test.err || major != test.major || minor != test.minor {
+			t.Errorf("On %q, expected %v.%v/%v, got %v.%v/%v", test.out, test.major, test.minor, test.err, major, minor, err)
+		}
+	}
+}
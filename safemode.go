@@ -0,0 +1,139 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"os/exec"
+	"sort"
+	"strings"
+	"time"
+)
+
+// archiveRefPrefix holds refs of history rewritten by upstream,
+// eg. refs/mirror-archive/20160102T150405Z/heads/main
+const archiveRefPrefix = "refs/mirror-archive/"
+
+// rewrittenRef is a protected ref the push would
+// force-update or delete, with NewSha empty for deletions.
+type rewrittenRef struct {
+	Ref    string
+	OldSha string
+	NewSha string
+}
+
+func (r rewrittenRef) String() string {
+	if r.NewSha == "" {
+		return fmt.Sprintf("%v: deleted, was %v", r.Ref, r.OldSha)
+	}
+	return fmt.Sprintf("%v: %v rewritten to %v", r.Ref, r.OldSha, r.NewSha)
+}
+
+func isProtectedRef(ref string) bool {
+	for _, pattern := range splitPatterns(*protected_refs) {
+		if matchRef(pattern, ref) {
+			return true
+		}
+	}
+	return false
+}
+
+// isAncestor returns false also if old is not known locally,
+// as then it can't be part of the local history.
+func isAncestor(old, new string) bool {
+	return exec.Command(*git, "merge-base", "--is-ancestor", old, new).Run() == nil
+}
+
+// rewrittenRefs lists mirrored, protected refs of the remote that
+// are gone locally or don't fast-forward. Any change of a tag counts.
+func rewrittenRefs(local, remote map[string]string) []rewrittenRef {
+	var rewritten []rewrittenRef
+	for ref, oldSha := range remote {
+		if !isProtectedRef(ref) || !ref_filter.matches(ref) {
+			continue
+		}
+
+		newSha, ok := local[ref]
+		if !ok {
+			rewritten = append(rewritten, rewrittenRef{Ref: ref, OldSha: oldSha})
+		} else if newSha != oldSha && (strings.HasPrefix(ref, "refs/tags/") || !isAncestor(oldSha, newSha)) {
+			rewritten = append(rewritten, rewrittenRef{Ref: ref, OldSha: oldSha, NewSha: newSha})
+		}
+	}
+	sort.Slice(rewritten, func(i, j int) bool {
+		return rewritten[i].Ref < rewritten[j].Ref
+	})
+	return rewritten
+}
+
+// archiveRefs copies the refs within the remote. They are fetched
+// first, as upstream history may not have the old commits anymore.
+func archiveRefs(remote string, refs []rewrittenRef) error {
+	prefix := archiveRefPrefix + time.Now().UTC().Format("20060102T150405Z") + "/"
+
+	var refspecs []string
+	for _, r := range refs {
+		archive_ref := prefix + strings.TrimPrefix(r.Ref, "refs/")
+		refspecs = append(refspecs, "+"+r.Ref+":"+archive_ref)
+	}
+
+	fetch := gitlabCommand(append([]string{"fetch", "--no-tags", remote}, refspecs...)...)
+	out, err := fetch.CombinedOutput()
+	if err != nil {
+		return fmt.Errorf("failed to fetch refs from %v: %v\n%s", remote, err, out)
+	}
+
+	var archived []string
+	for _, refspec := range refspecs {
+		archived = append(archived, refspec[strings.Index(refspec, ":")+1:])
+	}
+	defer func() {
+		// local copies are not needed anymore
+		for _, archive_ref := range archived {
+			exec.Command(*git, "update-ref", "-d", archive_ref).Run()
+		}
+	}()
+
+	push := gitlabCommand(append([]string{"push", remote}, archived...)...)
+	out, err = push.CombinedOutput()
+	if err != nil {
+		return fmt.Errorf("failed to push archived refs to %v: %v\n%s", remote, err, out)
+	}
+	return nil
+}
+
+// doProtectRefs looks for force-pushes and deletions of protected refs
+// before they are mirrored. Depending on safe_mode their old state is
+// archived on the remote or the push is refused.
+func doProtectRefs() {
+	if *safe_mode == "off" {
+		return
+	}
+
+	log.Printf("Checking %v for rewritten history...", *gitlab_remote)
+	local, err := localRefs()
+	if err != nil {
+		log.Fatalf("Failed to list local refs: %v", err)
+	}
+	remote, err := remoteRefs(*gitlab_remote)
+	if err != nil {
+		log.Fatalf("Failed to list refs of %v: %v", *gitlab_remote, err)
+	}
+
+	rewritten := rewrittenRefs(local, remote)
+	if len(rewritten) == 0 {
+		return
+	}
+	for _, r := range rewritten {
+		log.Printf("Protected ref %v", r)
+	}
+
+	if *safe_mode == "refuse" {
+		log.Fatalf("Refusing to push %d rewritten protected refs to %v.", len(rewritten), *gitlab_remote)
+	}
+
+	log.Printf("Archiving %d rewritten protected refs in %v...", len(rewritten), *gitlab_remote)
+	err = archiveRefs(*gitlab_remote, rewritten)
+	if err != nil {
+		log.Fatalf("Failed to archive protected refs: %v", err)
+	}
+}
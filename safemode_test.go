@@ -0,0 +1,107 @@
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// commitTree creates a repository in dir with main
+// at c2, its parent c1, and c3 forked from c1. It returns their shas.
+func commitTree(t *testing.T, dir string) (c1, c2, c3 string) {
+	run := func(args ...string) string {
+		cmd := exec.Command("git", args...)
+		cmd.Dir = dir
+		cmd.Env = append(os.Environ(),
+			"GIT_AUTHOR_NAME=test", "GIT_AUTHOR_EMAIL=test@example.com",
+			"GIT_COMMITTER_NAME=test", "GIT_COMMITTER_EMAIL=test@example.com")
+		out, err := cmd.CombinedOutput()
+		if err != nil {
+			t.Fatalf("git %v: %v\n%s", strings.Join(args, " "), err, out)
+		}
+		return strings.TrimSpace(string(out))
+	}
+
+	run("init", "-q")
+	run("commit", "-q", "--allow-empty", "-m", "c1")
+	c1 = run("rev-parse", "HEAD")
+	run("commit", "-q", "--allow-empty", "-m", "c2")
+	c2 = run("rev-parse", "HEAD")
+	run("checkout", "-q", "-b", "fork", c1)
+	run("commit", "-q", "--allow-empty", "-m", "c3")
+	c3 = run("rev-parse", "HEAD")
+	return
+}
+
+func TestRewrittenRefs(t *testing.T) {
+	dir, err := ioutil.TempDir("", "safemode")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	c1, c2, c3 := commitTree(t, dir)
+	unknown := "0123456789012345678901234567890123456789"
+
+	wd, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.Chdir(wd)
+	if err := os.Chdir(dir); err != nil {
+		t.Fatal(err)
+	}
+
+	defer func(filter *refFilter, protected string) {
+		ref_filter, *protected_refs = filter, protected
+	}(ref_filter, *protected_refs)
+	*protected_refs = "refs/heads/*,refs/tags/*"
+
+	tests := []struct {
+		filter *refFilter
+		local  map[string]string
+		remote map[string]string
+		exp    []rewrittenRef
+	}{
+		// fast-forwards and new refs are fine
+		{nil, map[string]string{"refs/heads/main": c2, "refs/heads/new": c3},
+			map[string]string{"refs/heads/main": c1}, nil},
+		{nil, map[string]string{"refs/heads/main": c2},
+			map[string]string{"refs/heads/main": c2}, nil},
+		{nil, map[string]string{"refs/heads/main": c1},
+			map[string]string{"refs/heads/main": c2},
+			[]rewrittenRef{{"refs/heads/main", c2, c1}}},
+		{nil, map[string]string{"refs/heads/main": c3},
+			map[string]string{"refs/heads/main": c2},
+			[]rewrittenRef{{"refs/heads/main", c2, c3}}},
+		// commits gone from upstream are not ancestors
+		{nil, map[string]string{"refs/heads/main": c2},
+			map[string]string{"refs/heads/main": unknown},
+			[]rewrittenRef{{"refs/heads/main", unknown, c2}}},
+		// tags are rewritten even if they move forward
+		{nil, map[string]string{"refs/tags/v1": c2},
+			map[string]string{"refs/tags/v1": c1},
+			[]rewrittenRef{{"refs/tags/v1", c1, c2}}},
+		{nil, map[string]string{},
+			map[string]string{"refs/heads/gone": c1, "refs/tags/gone": c2},
+			[]rewrittenRef{{"refs/heads/gone", c1, ""}, {"refs/tags/gone", c2, ""}}},
+		// unprotected refs and refs not mirrored are left alone
+		{nil, map[string]string{},
+			map[string]string{"refs/pull/1/head": c1, "refs/mirror-archive/x/heads/main": c1}, nil},
+		{&refFilter{Include: []string{"refs/*"}, Exclude: []string{"refs/heads/tmp-*"}},
+			map[string]string{"refs/heads/main": c1},
+			map[string]string{"refs/heads/main": c2, "refs/heads/tmp-1": c1},
+			[]rewrittenRef{{"refs/heads/main", c2, c1}}},
+	}
+
+	for _, test := range tests {
+		ref_filter = test.filter
+		got := rewrittenRefs(test.local, test.remote)
+		if !reflect.DeepEqual(got, test.exp) {
+			t.Errorf("On %v to %v, expected %v, got %v", test.remote, test.local, test.exp, got)
+		}
+	}
+}
@@ -0,0 +1,138 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"strings"
+
+	"github.com/ayufan/gitlab-mirror-post-fetch/gitlab"
+)
+
+// projectSettings are applied to created projects. They are read
+// from the configuration file, from the matching rule and from flags,
+// in this order, each overriding values set by the previous ones.
+type projectSettings struct {
+	Issues            string   `json:"issues,omitempty"`
+	MergeRequests     string   `json:"merge_requests,omitempty"`
+	Wiki              string   `json:"wiki,omitempty"`
+	Snippets          string   `json:"snippets,omitempty"`
+	Builds            string   `json:"builds,omitempty"`
+	ContainerRegistry string   `json:"container_registry,omitempty"`
+	DefaultBranch     string   `json:"default_branch,omitempty"`
+	Description       string   `json:"description,omitempty"`
+	Topics            []string `json:"topics,omitempty"`
+}
+
+var (
+	project_issues             = flag.String("project-issues", getEnvOrDefault("GITLAB_PROJECT_ISSUES", ""), "Issues access level of created projects: disabled, private or enabled [GITLAB_PROJECT_ISSUES]")
+	project_merge_requests     = flag.String("project-merge-requests", getEnvOrDefault("GITLAB_PROJECT_MERGE_REQUESTS", ""), "Merge requests access level of created projects [GITLAB_PROJECT_MERGE_REQUESTS]")
+	project_wiki               = flag.String("project-wiki", getEnvOrDefault("GITLAB_PROJECT_WIKI", ""), "Wiki access level of created projects [GITLAB_PROJECT_WIKI]")
+	project_snippets           = flag.String("project-snippets", getEnvOrDefault("GITLAB_PROJECT_SNIPPETS", ""), "Snippets access level of created projects [GITLAB_PROJECT_SNIPPETS]")
+	project_builds             = flag.String("project-builds", getEnvOrDefault("GITLAB_PROJECT_BUILDS", ""), "CI/CD access level of created projects [GITLAB_PROJECT_BUILDS]")
+	project_container_registry = flag.String("project-container-registry", getEnvOrDefault("GITLAB_PROJECT_CONTAINER_REGISTRY", ""), "Container registry access level of created projects [GITLAB_PROJECT_CONTAINER_REGISTRY]")
+	project_default_branch     = flag.String("project-default-branch", getEnvOrDefault("GITLAB_PROJECT_DEFAULT_BRANCH", ""), "Default branch of projects, overrides the upstream one [GITLAB_PROJECT_DEFAULT_BRANCH]")
+	project_description        = flag.String("project-description", getEnvOrDefault("GITLAB_PROJECT_DESCRIPTION", ""), "Description template of created projects [GITLAB_PROJECT_DESCRIPTION]")
+	project_topics             = flag.String("project-topics", getEnvOrDefault("GITLAB_PROJECT_TOPICS", ""), "Comma separated topics added to created projects [GITLAB_PROJECT_TOPICS]")
+)
+
+// defaultProjectSettings keep mirrors free of anything
+// that would diverge from upstream.
+var defaultProjectSettings = projectSettings{
+	Issues:        "disabled",
+	MergeRequests: "disabled",
+	Wiki:          "disabled",
+	Snippets:      "disabled",
+	Description:   "Mirror of {{.URL}}{{if .Description}}: {{.Description}}{{end}}{{if .Homepage}} ({{.Homepage}}){{end}}",
+}
+
+func flagProjectSettings() *projectSettings {
+	settings := &projectSettings{
+		Issues:            *project_issues,
+		MergeRequests:     *project_merge_requests,
+		Wiki:              *project_wiki,
+		Snippets:          *project_snippets,
+		Builds:            *project_builds,
+		ContainerRegistry: *project_container_registry,
+		DefaultBranch:     *project_default_branch,
+		Description:       *project_description,
+	}
+	for _, topic := range strings.Split(*project_topics, ",") {
+		if topic = strings.TrimSpace(topic); topic != "" {
+			settings.Topics = append(settings.Topics, topic)
+		}
+	}
+	return settings
+}
+
+func contains(haystack []string, needle string) bool {
+	for _, n := range haystack {
+		if n == needle {
+			return true
+		}
+	}
+	return false
+}
+
+// merge overrides settings with values set in other.
+// Topics are accumulated.
+func (s *projectSettings) merge(other *projectSettings) {
+	if other == nil {
+		return
+	}
+
+	fields := []struct {
+		value *string
+		other string
+	}{
+		{&s.Issues, other.Issues},
+		{&s.MergeRequests, other.MergeRequests},
+		{&s.Wiki, other.Wiki},
+		{&s.Snippets, other.Snippets},
+		{&s.Builds, other.Builds},
+		{&s.ContainerRegistry, other.ContainerRegistry},
+		{&s.DefaultBranch, other.DefaultBranch},
+		{&s.Description, other.Description},
+	}
+	for _, field := range fields {
+		if field.other != "" {
+			*field.value = field.other
+		}
+	}
+
+	for _, topic := range other.Topics {
+		if !contains(s.Topics, topic) {
+			s.Topics = append(s.Topics, topic)
+		}
+	}
+}
+
+func (s *projectSettings) validate() error {
+	if s == nil {
+		return nil
+	}
+
+	levels := map[string]string{
+		"issues":             s.Issues,
+		"merge_requests":     s.MergeRequests,
+		"wiki":               s.Wiki,
+		"snippets":           s.Snippets,
+		"builds":             s.Builds,
+		"container_registry": s.ContainerRegistry,
+	}
+	for name, level := range levels {
+		if level != "" && !contains(gitlab.AccessLevels, level) {
+			return fmt.Errorf("unsupported %v access level: %v", name, level)
+		}
+	}
+	return nil
+}
+
+func (s *projectSettings) apply(project *gitlab.CreateProject) {
+	project.IssuesAccessLevel = s.Issues
+	project.MergeRequestsAccessLevel = s.MergeRequests
+	project.WikiAccessLevel = s.Wiki
+	project.SnippetsAccessLevel = s.Snippets
+	project.BuildsAccessLevel = s.Builds
+	project.ContainerRegistryAccessLevel = s.ContainerRegistry
+	project.Topics = s.Topics
+}
@@ -0,0 +1,73 @@
+package main
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/pem"
+)
+
+// sshString encodes data as a string of the SSH wire format.
+func sshString(data []byte) []byte {
+	b := make([]byte, 4, 4+len(data))
+	binary.BigEndian.PutUint32(b, uint32(len(data)))
+	return append(b, data...)
+}
+
+func marshalED25519PublicKey(pub ed25519.PublicKey) []byte {
+	b := sshString([]byte("ssh-ed25519"))
+	return append(b, sshString(pub)...)
+}
+
+// marshalED25519PrivateKey writes the key in the unencrypted
+// openssh-key-v1 format understood by ssh.
+func marshalED25519PrivateKey(pub ed25519.PublicKey, priv ed25519.PrivateKey, comment string) ([]byte, error) {
+	check := make([]byte, 4)
+	_, err := rand.Read(check)
+	if err != nil {
+		return nil, err
+	}
+
+	var keys []byte
+	keys = append(keys, check...)
+	keys = append(keys, check...)
+	keys = append(keys, sshString([]byte("ssh-ed25519"))...)
+	keys = append(keys, sshString(pub)...)
+	keys = append(keys, sshString(priv)...)
+	keys = append(keys, sshString([]byte(comment))...)
+	for i := byte(1); len(keys)%8 != 0; i++ {
+		keys = append(keys, i)
+	}
+
+	var b []byte
+	b = append(b, "openssh-key-v1\x00"...)
+	b = append(b, sshString([]byte("none"))...)
+	b = append(b, sshString([]byte("none"))...)
+	b = append(b, sshString(nil)...)
+	b = append(b, 0, 0, 0, 1)
+	b = append(b, sshString(marshalED25519PublicKey(pub))...)
+	b = append(b, sshString(keys)...)
+
+	return pem.EncodeToMemory(&pem.Block{Type: "OPENSSH PRIVATE KEY", Bytes: b}), nil
+}
+
+// generateSSHKey returns a new ed25519 key pair as the private key
+// file and the authorized_keys line of the public key.
+func generateSSHKey(comment string) ([]byte, string, error) {
+	pub, priv, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		return nil, "", err
+	}
+
+	private_key, err := marshalED25519PrivateKey(pub, priv, comment)
+	if err != nil {
+		return nil, "", err
+	}
+
+	public_key := "ssh-ed25519 " + base64.StdEncoding.EncodeToString(marshalED25519PublicKey(pub))
+	if comment != "" {
+		public_key += " " + comment
+	}
+	return private_key, public_key, nil
+}
@@ -0,0 +1,103 @@
+package main
+
+import (
+	"bytes"
+	"crypto/ed25519"
+	"crypto/rand"
+	"encoding/binary"
+	"encoding/pem"
+	"testing"
+)
+
+// readSSHString splits a string of the SSH wire format from b.
+func readSSHString(t *testing.T, b []byte) ([]byte, []byte) {
+	if len(b) < 4 {
+		t.Fatalf("Expected string length, got %q", b)
+	}
+	n := binary.BigEndian.Uint32(b)
+	if uint32(len(b)-4) < n {
+		t.Fatalf("Expected %v bytes, got %q", n, b[4:])
+	}
+	return b[4 : 4+n], b[4+n:]
+}
+
+func TestMarshalED25519PrivateKey(t *testing.T) {
+	pub, priv, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	data, err := marshalED25519PrivateKey(pub, priv, "mirror@example.com")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	block, rest := pem.Decode(data)
+	if block == nil || len(rest) != 0 {
+		t.Fatalf("Expected a single PEM block, got %q", data)
+	}
+	if block.Type != "OPENSSH PRIVATE KEY" {
+		t.Errorf("Expected OPENSSH PRIVATE KEY, got %v", block.Type)
+	}
+
+	b := block.Bytes
+	if !bytes.HasPrefix(b, []byte("openssh-key-v1\x00")) {
+		t.Fatalf("Expected openssh-key-v1 magic, got %q", b)
+	}
+	b = b[len("openssh-key-v1\x00"):]
+
+	var cipher, kdf, kdf_options, public_key, keys []byte
+	cipher, b = readSSHString(t, b)
+	kdf, b = readSSHString(t, b)
+	kdf_options, b = readSSHString(t, b)
+	if string(cipher) != "none" || string(kdf) != "none" || len(kdf_options) != 0 {
+		t.Errorf("Expected unencrypted key, got %q, %q, %q", cipher, kdf, kdf_options)
+	}
+	if len(b) < 4 || binary.BigEndian.Uint32(b) != 1 {
+		t.Fatalf("Expected one key, got %q", b)
+	}
+	public_key, b = readSSHString(t, b[4:])
+	if !bytes.Equal(public_key, marshalED25519PublicKey(pub)) {
+		t.Errorf("Expected public key %q, got %q", marshalED25519PublicKey(pub), public_key)
+	}
+	keys, b = readSSHString(t, b)
+	if len(b) != 0 {
+		t.Errorf("Unexpected trailing data %q", b)
+	}
+
+	if len(keys)%8 != 0 {
+		t.Errorf("Expected private keys padded to 8 bytes, got %v", len(keys))
+	}
+	if len(keys) < 8 || !bytes.Equal(keys[0:4], keys[4:8]) {
+		t.Fatalf("Expected matching check numbers, got %q", keys)
+	}
+
+	var key_type, key_pub, key_priv, comment []byte
+	key_type, keys = readSSHString(t, keys[8:])
+	key_pub, keys = readSSHString(t, keys)
+	key_priv, keys = readSSHString(t, keys)
+	comment, keys = readSSHString(t, keys)
+	if string(key_type) != "ssh-ed25519" {
+		t.Errorf("Expected ssh-ed25519, got %q", key_type)
+	}
+	if !bytes.Equal(key_pub, pub) {
+		t.Errorf("Expected public key %x, got %x", []byte(pub), key_pub)
+	}
+	if string(comment) != "mirror@example.com" {
+		t.Errorf("Expected comment mirror@example.com, got %q", comment)
+	}
+	for i, c := range keys {
+		if c != byte(i+1) {
+			t.Errorf("Expected padding 1, 2, 3..., got %v", keys)
+			break
+		}
+	}
+
+	if len(key_priv) != ed25519.PrivateKeySize {
+		t.Fatalf("Expected %v bytes of private key, got %v", ed25519.PrivateKeySize, len(key_priv))
+	}
+	signature := ed25519.Sign(ed25519.PrivateKey(key_priv), []byte("message"))
+	if !ed25519.Verify(pub, []byte("message"), signature) {
+		t.Errorf("Parsed private key doesn't match the public key")
+	}
+}
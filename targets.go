@@ -0,0 +1,147 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"os/exec"
+	"strings"
+)
+
+// gitlabTarget is a GitLab or Gitea instance the repository is mirrored to,
+// configured in targets of the configuration file. Empty fields keep
+// values of the flags.
+type gitlabTarget struct {
+	Name          string `json:"name"`
+	Provider      string `json:"provider"`
+	URL           string `json:"url"`
+	APIPath       string `json:"api_path"`
+	PrivateToken  string `json:"private_token"`
+	Group         string `json:"group"`
+	Visibility    string `json:"visibility"`
+	Remote        string `json:"remote"`
+	PushTransport string `json:"push_transport"`
+	PushUsername  string `json:"push_username"`
+	PushToken     string `json:"push_token"`
+}
+
+func validateTargets(targets []*gitlabTarget) error {
+	names := make(map[string]bool)
+	remotes := make(map[string]bool)
+
+	for i, t := range targets {
+		if t.Name == "" {
+			return fmt.Errorf("target %d has no name", i+1)
+		}
+		if names[t.Name] {
+			return fmt.Errorf("target %v is defined twice", t.Name)
+		}
+		names[t.Name] = true
+
+		if t.Remote == "" {
+			t.Remote = t.Name
+		}
+		if remotes[t.Remote] {
+			return fmt.Errorf("remote %v of target %v is used by another target", t.Remote, t.Name)
+		}
+		remotes[t.Remote] = true
+	}
+	return nil
+}
+
+func findTarget(name string) *gitlabTarget {
+	for _, t := range config.Targets {
+		if t.Name == name {
+			return t
+		}
+	}
+	return nil
+}
+
+func setIfNotEmpty(value *string, new_value string) {
+	if new_value != "" {
+		*value = new_value
+	}
+}
+
+// apply overrides the flags with the target. Tokens can reference
+// environment variables, eg. $DR_GITLAB_TOKEN
+func (t *gitlabTarget) apply() {
+	setIfNotEmpty(provider_name, t.Provider)
+	setIfNotEmpty(address, t.URL)
+	setIfNotEmpty(api_path, t.APIPath)
+	setIfNotEmpty(private_token, os.ExpandEnv(t.PrivateToken))
+	setIfNotEmpty(group, t.Group)
+	setIfNotEmpty(visibility_level, t.Visibility)
+	setIfNotEmpty(gitlab_remote, t.Remote)
+	setIfNotEmpty(push_transport, t.PushTransport)
+	setIfNotEmpty(push_username, t.PushUsername)
+	setIfNotEmpty(push_token, os.ExpandEnv(t.PushToken))
+}
+
+// prefixWriter starts every line written to w with prefix.
+type prefixWriter struct {
+	w      io.Writer
+	prefix string
+	buf    []byte
+}
+
+func (p *prefixWriter) Write(data []byte) (int, error) {
+	p.buf = append(p.buf, data...)
+	for {
+		i := bytes.IndexAny(p.buf, "\r\n")
+		if i < 0 {
+			break
+		}
+		_, err := fmt.Fprintf(p.w, "%v%s", p.prefix, p.buf[:i+1])
+		if err != nil {
+			return 0, err
+		}
+		p.buf = p.buf[i+1:]
+	}
+	return len(data), nil
+}
+
+func (p *prefixWriter) Flush() {
+	if len(p.buf) > 0 {
+		fmt.Fprintf(p.w, "%v%s\n", p.prefix, p.buf)
+		p.buf = nil
+	}
+}
+
+// doMirrorTargets runs the tool once for every target. Each target
+// runs in its own process, so a failing target does not stop the others.
+func doMirrorTargets() {
+	executable, err := os.Executable()
+	if err != nil {
+		log.Fatalf("Failed to find executable: %v", err)
+	}
+	payload := readStdin()
+
+	var failed []string
+	for _, t := range config.Targets {
+		log.Printf("Mirroring to %v...", t.Name)
+
+		stdout := &prefixWriter{w: os.Stdout, prefix: "[" + t.Name + "] "}
+		stderr := &prefixWriter{w: os.Stderr, prefix: "[" + t.Name + "] "}
+		cmd := exec.Command(executable, append([]string{"-target=" + t.Name}, os.Args[1:]...)...)
+		cmd.Stdin = bytes.NewReader(payload)
+		cmd.Stdout = stdout
+		cmd.Stderr = stderr
+		err = cmd.Run()
+		stdout.Flush()
+		stderr.Flush()
+
+		if err != nil {
+			log.Printf("Mirroring to %v failed: %v", t.Name, err)
+			failed = append(failed, t.Name)
+		}
+	}
+
+	if len(failed) > 0 {
+		log.Fatalf("Mirroring failed for %d of %d targets: %v",
+			len(failed), len(config.Targets), strings.Join(failed, ", "))
+	}
+}
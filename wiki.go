@@ -0,0 +1,136 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"log"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+
+	"github.com/ayufan/gitlab-mirror-post-fetch/gitlab"
+)
+
+// wikiURL returns the URL of the wiki of the repository,
+// eg. git@github.com:foo/bar.wiki.git
+func wikiURL(repo_url string) string {
+	return strings.TrimSuffix(repo_url, ".git") + ".wiki.git"
+}
+
+// wikiDir holds a mirror clone of the upstream wiki.
+func wikiDir() string {
+	return filepath.Join(stateDir(), "wiki.git")
+}
+
+// missingRepositoryPatterns are reported by git hosts for repositories
+// that don't exist. GitHub has no wiki repository until the first page
+// is created.
+var missingRepositoryPatterns = []string{
+	"Repository not found",
+	"repository not found",
+	"does not appear to be a git repository",
+}
+
+// hasWiki checks whether the wiki repository exists and has a HEAD.
+// Other failures, eg. of network or authentication, are returned.
+func hasWiki(url string) (bool, error) {
+	var stderr bytes.Buffer
+	cmd := exec.Command(*git, "ls-remote", "--exit-code", url, "HEAD")
+	cmd.Stderr = &stderr
+	err := cmd.Run()
+	if err == nil {
+		return true, nil
+	}
+
+	// --exit-code reports a repository without HEAD with status 2
+	if exit_err, ok := err.(*exec.ExitError); ok && exit_err.ExitCode() == 2 {
+		return false, nil
+	}
+	if isMissingRepository(stderr.String()) {
+		return false, nil
+	}
+	return false, fmt.Errorf("failed to list %v: %v\n%s", url, err, stderr.Bytes())
+}
+
+func isMissingRepository(output string) bool {
+	for _, pattern := range missingRepositoryPatterns {
+		if strings.Contains(output, pattern) {
+			return true
+		}
+	}
+	return false
+}
+
+// fetchWiki clones or updates the upstream wiki. It returns false
+// if upstream has no wiki.
+func fetchWiki() (bool, error) {
+	origin_url := getGitConfig(fmt.Sprintf("remote.%v.url", *origin_remote))
+	if origin_url == "" {
+		return false, fmt.Errorf("no URL defined for %v", *origin_remote)
+	}
+
+	dir := wikiDir()
+	url := wikiURL(origin_url)
+	if found, err := hasWiki(url); !found || err != nil {
+		return false, err
+	}
+
+	var cmd *exec.Cmd
+	if exists(dir) {
+		log.Printf("Fetching wiki from %v...", url)
+		cmd = exec.Command(*git, "--git-dir="+dir, "fetch", "--prune", "origin")
+	} else {
+		log.Printf("Cloning wiki from %v...", url)
+		err := os.MkdirAll(stateDir(), 0700)
+		if err != nil {
+			return false, err
+		}
+		cmd = exec.Command(*git, "clone", "--mirror", url, dir)
+	}
+
+	cmd.Stdout = os.Stderr
+	cmd.Stderr = os.Stderr
+	err := cmd.Run()
+	if err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+func doEnableWiki() {
+	project_data := doFindRemoteProject()
+	if project_data.WikiEnabled || gitlab.AccessLevelEnabled(project_data.WikiAccessLevel) {
+		return
+	}
+
+	log.Printf("Enabling wiki of %v...", project_data.PathWithNamespace)
+	edited, err := client.EditProject(project_data.Id, gitlab.EditProject{WikiAccessLevel: "enabled"})
+	if err != nil {
+		log.Fatalf("Failed to enable wiki of %v: %v", project_data.PathWithNamespace, err)
+	}
+	remote_project = edited
+}
+
+// doMirrorWiki pushes the upstream wiki to the wiki of the project.
+func doMirrorWiki() {
+	found, err := fetchWiki()
+	if err != nil {
+		log.Fatalf("Failed to fetch wiki: %v", err)
+	} else if !found {
+		log.Printf("Upstream has no wiki.")
+		return
+	}
+
+	doEnableWiki()
+
+	url := wikiURL(getGitConfig(fmt.Sprintf("remote.%v.url", *gitlab_remote)))
+	log.Printf("Pushing wiki to %v...", url)
+	cmd := gitlabCommand("--git-dir="+wikiDir(), "push", "--mirror", url)
+	cmd.Stdout = os.Stderr
+	cmd.Stderr = os.Stderr
+	err = cmd.Run()
+	if err != nil {
+		log.Fatalf("Failed to push wiki to %v: %v", url, err)
+	}
+}
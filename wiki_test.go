@@ -0,0 +1,69 @@
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"testing"
+)
+
+func TestHasWiki(t *testing.T) {
+	dir, err := ioutil.TempDir("", "wiki")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	empty := filepath.Join(dir, "empty.wiki.git")
+	if out, err := exec.Command("git", "init", "-q", "--bare", empty).CombinedOutput(); err != nil {
+		t.Fatalf("git init: %v\n%s", err, out)
+	}
+	pages := filepath.Join(dir, "pages.wiki.git")
+	if err := os.Mkdir(pages, 0700); err != nil {
+		t.Fatal(err)
+	}
+	commitTree(t, pages)
+
+	tests := []struct {
+		url   string
+		found bool
+		err   bool
+	}{
+		{pages, true, false},
+		{empty, false, false},
+		{filepath.Join(dir, "missing.wiki.git"), false, false},
+		// unreachable hosts fail instead of skipping the wiki
+		{"http://127.0.0.1:1/foo/bar.wiki.git", false, true},
+	}
+
+	for _, test := range tests {
+		found, err := hasWiki(test.url)
+		if found != test.found || (err != nil) != test.err {
+			t.Errorf("On %v, expected %v/%v, got %v/%v", test.url, test.found, test.err, found, err)
+		}
+	}
+}
+
+func TestIsMissingRepository(t *testing.T) {
+	tests := []struct {
+		output string
+		exp    bool
+	}{
+		{"remote: Repository not found.\n" +
+			"fatal: repository 'https://github.com/foo/bar.wiki.git/' not found\n", true},
+		{"ERROR: Repository not found.\n" +
+			"fatal: Could not read from remote repository.\n", true},
+		{"fatal: '/repos/foo.wiki.git' does not appear to be a git repository\n", true},
+		{"fatal: unable to access 'https://github.com/foo/bar.wiki.git/': Could not resolve host: github.com\n", false},
+		{"git@github.com: Permission denied (publickey).\n" +
+			"fatal: Could not read from remote repository.\n", false},
+	}
+
+	for _, test := range tests {
+		got := isMissingRepository(test.output)
+		if got != test.exp {
+			t.Errorf("On %q, expected %v, got %v", test.output, test.exp, got)
+		}
+	}
+}